@@ -1,6 +1,8 @@
 package vyperclientgo
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,65 +10,260 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const defaultRetryDelay = 500 * time.Millisecond
+
+// VyperClient calls the REST API. If Credentials is set, it is asked for the
+// API key on every request instead of using ApiKey. Failed requests are retried up to
+// MaxRetries times on 429 and 503 responses, waiting RetryDelay, doubled on
+// every attempt, or the server's Retry-After if longer. Network errors,
+// truncated responses and other 5xx responses are retried only for
+// idempotent methods, since the server may already have processed the
+// request; set RetryNonIdempotent to retry them for POST and PATCH too. Set
+// CompressRequests to gzip request bodies, and RequestsPerSecond to space out
+// requests, retries included.
 type VyperClient struct {
-	BaseURL    string
-	ApiKey     string
-	HttpClient *http.Client
+	BaseURL            string
+	ApiKey             string
+	Credentials        CredentialsProvider
+	HttpClient         *http.Client
+	ResponseInspector  ResponseInspector
+	MaxRetries         int
+	RetryDelay         time.Duration
+	RetryNonIdempotent bool
+	CompressRequests   bool
+	RequestsPerSecond  float64
+
+	limiterMu   sync.Mutex
+	nextRequest time.Time
 }
 
 func NewVyperClient(apiKey string) *VyperClient {
 	return &VyperClient{
-		BaseURL: "https://api.vyper.trade",
+		BaseURL: defaultBaseURL,
 		ApiKey:  apiKey,
 		HttpClient: &http.Client{
-			Timeout: time.Second * 10,
+			Timeout: defaultTimeout,
 		},
 	}
 }
 
 func (c *VyperClient) request(method, endpoint string, params map[string]string) ([]byte, error) {
-	req, err := http.NewRequest(method, c.BaseURL+endpoint, nil)
+	return c.requestWithBody(method, endpoint, params, nil)
+}
+
+// requestWithBody sends body, if not nil, encoded as JSON. The encoded body is
+// kept so every retry sends it again from the start.
+func (c *VyperClient) requestWithBody(method, endpoint string, params map[string]string, body interface{}) ([]byte, error) {
+	var payload []byte
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = data
+		if c.CompressRequests {
+			if payload, err = gzipBytes(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	delay := c.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		respBody, retryAfter, retry, err := c.attempt(method, endpoint, params, payload, attempt, start)
+		if !retry || attempt > c.MaxRetries {
+			return respBody, err
+		}
+
+		wait := delay
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+// attempt sends the request once. It reports whether a failure is worth
+// retrying and how long the server asked to wait.
+func (c *VyperClient) attempt(method, endpoint string, params map[string]string, payload []byte, attempt int, start time.Time) ([]byte, time.Duration, bool, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	apiKey, err := resolveApiKey(c.Credentials, c.ApiKey)
 	if err != nil {
-		return nil, err
+		return nil, 0, false, err
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+endpoint, reqBody)
+	if err != nil {
+		return nil, 0, false, err
 	}
 
-	req.Header.Set("X-API-Key", c.ApiKey)
+	req.Header.Set("X-API-Key", apiKey)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.CompressRequests {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
 	q := req.URL.Query()
 	for key, value := range params {
 		q.Add(key, value)
 	}
 	req.URL.RawQuery = q.Encode()
 
+	c.waitForSlot()
+	sent := time.Now()
 	resp, err := c.HttpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, c.retriesTransport(method), err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, 0, c.retriesTransport(method), err
+	}
+
+	meta := newResponseMeta(method, endpoint, resp, time.Since(sent), attempt)
+	if c.ResponseInspector != nil {
+		c.ResponseInspector(meta)
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		apiErr := &VyperApiError{
+			Message:    fmt.Sprintf("HTTP error: %s", resp.Status),
+			StatusCode: resp.StatusCode,
+			Method:     method,
+			Endpoint:   endpoint,
+			Params:     redactParams(params, apiKey),
+			RequestID:  meta.RequestID,
+			Attempt:    attempt,
+			Elapsed:    time.Since(start),
+			Body:       redactSecret(truncateBody(respBody), apiKey),
+		}
+
+		// Bodies that are not API responses, such as error pages from a
+		// proxy, are kept in Body only.
 		var apiResp APIResponse
-		if err := json.Unmarshal(body, &apiResp); err != nil {
-			return nil, &VyperApiError{
-				Message:    fmt.Sprintf("HTTP error: %s", resp.Status),
-				StatusCode: resp.StatusCode,
+		if err := json.Unmarshal(respBody, &apiResp); err == nil {
+			if apiResp.Message != "" {
+				apiErr.Message = apiResp.Message
 			}
+			apiErr.Response = apiResp
 		}
-		return nil, &VyperApiError{
-			Message:    apiResp.Message,
-			StatusCode: resp.StatusCode,
-			Response:   apiResp,
+
+		var retryAfter time.Duration
+		if meta.RateLimit != nil {
+			retryAfter = meta.RateLimit.RetryAfter
 		}
+		return nil, retryAfter, c.retriesStatus(method, resp.StatusCode), apiErr
 	}
 
-	return body, nil
+	return respBody, 0, false, nil
+}
+
+// waitForSlot blocks until the next request is allowed by RequestsPerSecond.
+func (c *VyperClient) waitForSlot() {
+	if c.RequestsPerSecond <= 0 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / c.RequestsPerSecond)
+
+	c.limiterMu.Lock()
+	at := c.nextRequest
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	c.nextRequest = at.Add(interval)
+	c.limiterMu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
+
+// retriesTransport reports whether a request that failed without a complete
+// response may be sent again.
+func (c *VyperClient) retriesTransport(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return c.RetryNonIdempotent
+}
+
+// retriesStatus reports whether a response with status may be retried. 429
+// and 503 mean the request was not processed. Other 5xx statuses may follow a
+// write the backend already made, so they are retried like network errors.
+func (c *VyperClient) retriesStatus(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return c.retriesTransport(method)
+	}
+	return false
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Do calls an endpoint that has no dedicated method. path is relative to
+// BaseURL, params are sent as the query string and body, if not nil, as JSON.
+// The data of the response envelope is decoded into out unless out is nil;
+// data the API sends as a JSON-encoded string is decoded from that string.
+func (c *VyperClient) Do(method, path string, params map[string]string, body interface{}, out interface{}) error {
+	respBody, err := c.requestWithBody(method, path, params, body)
+	if err != nil {
+		return err
+	}
+
+	var apiResp struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return err
+	}
+
+	if apiResp.Status != "" && apiResp.Status != "success" {
+		return fmt.Errorf("%w: %s", ErrNonSuccessStatus, apiResp.Status)
+	}
+
+	if out == nil || len(apiResp.Data) == 0 {
+		return nil
+	}
+
+	data := []byte(apiResp.Data)
+	if _, wantsString := out.(*string); !wantsString && data[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			return err
+		}
+		data = []byte(encoded)
+	}
+	return json.Unmarshal(data, out)
 }
 
 func (c *VyperClient) GetChainIds() (map[string]int, error) {
@@ -86,7 +283,7 @@ func (c *VyperClient) GetChainIds() (map[string]int, error) {
 	}
 
 	if apiResp.Status != "success" {
-		return nil, fmt.Errorf("API returned non-success status: %s", apiResp.Status)
+		return nil, fmt.Errorf("%w: %s", ErrNonSuccessStatus, apiResp.Status)
 	}
 
 	return apiResp.Data, nil
@@ -173,7 +370,7 @@ func (c *VyperClient) GetTokenHolders(marketId string, chainId int) ([]TokenHold
 
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, 0, fmt.Errorf("unexpected data type for API response")
+		return nil, 0, ErrUnexpectedPayload
 	}
 
 	err = json.Unmarshal([]byte(dataStr), &result)
@@ -203,7 +400,7 @@ func (c *VyperClient) GetTokenMarkets(tokenMint string, chainId int) ([]TokenMar
 	var result []TokenMarket
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 
 	err = json.Unmarshal([]byte(dataStr), &result)
@@ -233,7 +430,7 @@ func (c *VyperClient) GetWalletHoldings(walletAddress string, chainId int) ([]Wa
 	var result []WalletHolding
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 
 	err = json.Unmarshal([]byte(dataStr), &result)
@@ -263,7 +460,7 @@ func (c *VyperClient) GetWalletAggregatedPnl(walletAddress string, chainId int)
 	var result WalletAggregatedPnL
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -293,7 +490,7 @@ func (c *VyperClient) GetWalletPnl(walletAddress string, marketId string, chainI
 	var result WalletPnL
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -322,7 +519,7 @@ func (c *VyperClient) GetTokenMetadata(chainId int, tokenMint string) (*TokenMet
 	var result TokenMetadata
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -351,7 +548,7 @@ func (c *VyperClient) GetTokenSymbol(chainId int, tokenMint string) (*TokenSymbo
 	var result TokenSymbol
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -380,7 +577,7 @@ func (c *VyperClient) GetTopTraders(marketId string, chainId int) ([]TopTrader,
 	var result []TopTrader
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -411,7 +608,7 @@ func (c *VyperClient) SearchTokens(criteria string, chainId *int) ([]TokenSearch
 	var result []TokenSearchResult
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -470,7 +667,7 @@ func (c *VyperClient) GetTokenPairs(params TokenPairsParams) (*TokenPairs, error
 	var result TokenPairs
 	dataStr, ok := apiResp.Data.(string)
 	if !ok {
-		return nil, fmt.Errorf("unexpected data type for API response")
+		return nil, ErrUnexpectedPayload
 	}
 	err = json.Unmarshal([]byte(dataStr), &result)
 	if err != nil {
@@ -1,11 +1,17 @@
 package vyperclientgo
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewVyperClient(t *testing.T) {
@@ -838,3 +844,351 @@ func TestGetTokenPairs(t *testing.T) {
 		t.Errorf("Expected token pairs to be %+v, but got %+v", expected, pairs)
 	}
 }
+
+func TestRequestErrorContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status":"error","message":"market not found"}`))
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+	}
+
+	_, err := client.request("GET", "/api/v1/token/ath", map[string]string{
+		"marketID": "market-1",
+		"apiKey":   "test-api-key",
+		"note":     "key=test-api-key",
+	})
+
+	apiErr, ok := err.(*VyperApiError)
+	if !ok {
+		t.Fatalf("Expected a VyperApiError, got %T: %v", err, err)
+	}
+	if apiErr.Message != "market not found" || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Unexpected message or status: %q, %d", apiErr.Message, apiErr.StatusCode)
+	}
+	if apiErr.Method != "GET" || apiErr.Endpoint != "/api/v1/token/ath" || apiErr.RequestID != "req-123" {
+		t.Errorf("Unexpected request context: %+v", apiErr)
+	}
+	if apiErr.Attempt != 1 || apiErr.Elapsed <= 0 {
+		t.Errorf("Expected attempt 1 and a positive elapsed time, got %d and %v", apiErr.Attempt, apiErr.Elapsed)
+	}
+	expectedParams := map[string]string{"marketID": "market-1", "apiKey": "REDACTED", "note": "key=REDACTED"}
+	if !reflect.DeepEqual(apiErr.Params, expectedParams) {
+		t.Errorf("Expected params %v, got %v", expectedParams, apiErr.Params)
+	}
+	if apiErr.Body != `{"status":"error","message":"market not found"}` {
+		t.Errorf("Expected the raw body, got %q", apiErr.Body)
+	}
+	if !strings.Contains(err.Error(), "GET /api/v1/token/ath") {
+		t.Errorf("Expected the endpoint in the error message, got %q", err.Error())
+	}
+}
+
+func TestRequestErrorNonJSONBody(t *testing.T) {
+	page := "<html><body>502 Bad Gateway</body></html>" + strings.Repeat(" ", maxErrorBody)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+	}
+
+	_, err := client.GetChainIds()
+	apiErr, ok := err.(*VyperApiError)
+	if !ok {
+		t.Fatalf("Expected a VyperApiError, got %T: %v", err, err)
+	}
+	if apiErr.Message != "HTTP error: 502 Bad Gateway" || apiErr.Response != nil {
+		t.Errorf("Unexpected message or response: %q, %v", apiErr.Message, apiErr.Response)
+	}
+	if !strings.HasPrefix(apiErr.Body, "<html><body>502 Bad Gateway</body></html>") {
+		t.Errorf("Expected the HTML page to be preserved, got %q", apiErr.Body)
+	}
+	if !strings.HasSuffix(apiErr.Body, "(truncated)") || len(apiErr.Body) > maxErrorBody+20 {
+		t.Errorf("Expected the body to be truncated, got %d bytes", len(apiErr.Body))
+	}
+}
+
+func TestDo(t *testing.T) {
+	type alert struct {
+		MarketId string  `json:"marketId"`
+		Price    float64 `json:"price"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "test-api-key" {
+			t.Errorf("Expected the API key header, got %q", r.Header.Get("X-API-Key"))
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/alerts":
+			if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("Expected a JSON POST, got %s with %q", r.Method, r.Header.Get("Content-Type"))
+			}
+			if r.URL.Query().Get("chainID") != "900" {
+				t.Errorf("Expected chainID=900, got %q", r.URL.RawQuery)
+			}
+			var got alert
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			json.NewEncoder(w).Encode(APIResponse{Status: "success", Data: got})
+		case "/api/v1/alerts/encoded":
+			w.Write([]byte(`{"status":"success","data":"{\"marketId\":\"market-2\",\"price\":2}"}`))
+		case "/api/v1/alerts/failed":
+			w.Write([]byte(`{"status":"error","message":"not allowed"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+	}
+
+	var created alert
+	err := client.Do("POST", "/api/v1/alerts", map[string]string{"chainID": "900"}, alert{MarketId: "market-1", Price: 1.5}, &created)
+	if err != nil {
+		t.Fatalf("Do returned an error: %v", err)
+	}
+	if created != (alert{MarketId: "market-1", Price: 1.5}) {
+		t.Errorf("Unexpected decoded data: %+v", created)
+	}
+
+	var encoded alert
+	if err := client.Do("GET", "/api/v1/alerts/encoded", nil, nil, &encoded); err != nil {
+		t.Fatalf("Do returned an error: %v", err)
+	}
+	if encoded.MarketId != "market-2" {
+		t.Errorf("Expected string-encoded data to be decoded, got %+v", encoded)
+	}
+
+	if err := client.Do("GET", "/api/v1/alerts/failed", nil, nil, nil); !errors.Is(err, ErrNonSuccessStatus) {
+		t.Errorf("Expected ErrNonSuccessStatus, got %v", err)
+	}
+
+	err = client.Do("DELETE", "/api/v1/missing", nil, nil, nil)
+	apiErr, ok := err.(*VyperApiError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || apiErr.Method != "DELETE" {
+		t.Errorf("Expected a VyperApiError for the DELETE, got %v", err)
+	}
+}
+
+func TestRequestRetriesRewindBody(t *testing.T) {
+	var attempts int
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"ok":true}}`))
+	}))
+	defer server.Close()
+
+	var inspected []int
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+		ResponseInspector: func(meta *ResponseMeta) {
+			inspected = append(inspected, meta.Attempt)
+		},
+	}
+
+	var out struct {
+		Ok bool `json:"ok"`
+	}
+	filter := TokenPairsParams{ChainIds: []int{1, 900}, TokenTypes: []string{"PumpfunTokens"}}
+	if err := client.Do("POST", "/api/v1/token/pairs", nil, filter, &out); err != nil {
+		t.Fatalf("Do returned an error: %v", err)
+	}
+	if !out.Ok {
+		t.Error("Expected the final response to be decoded")
+	}
+
+	expected, _ := json.Marshal(filter)
+	for i, body := range bodies {
+		if body != string(expected) {
+			t.Errorf("Attempt %d sent body %q, expected %q", i+1, body, expected)
+		}
+	}
+	if !reflect.DeepEqual(inspected, []int{1, 2, 3}) {
+		t.Errorf("Expected attempts 1 to 3 to be inspected, got %v", inspected)
+	}
+}
+
+func TestRequestRetriesExhausted(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	}
+
+	_, err := client.request("GET", "/limited", nil)
+	apiErr, ok := err.(*VyperApiError)
+	if !ok || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Attempt != 3 {
+		t.Fatalf("Expected a 429 after 3 attempts, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	attempts = 0
+	if _, err := client.request("GET", "/bad", nil); err == nil {
+		t.Fatal("Expected a bad request error")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a 400 not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestRequestRetriesTransportErrors(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		// Drop the connection without a response.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	}
+
+	if _, err := client.request("GET", "/api/v1/chain/ids", nil); err == nil {
+		t.Fatal("Expected a transport error")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Expected a GET to be retried, got %d attempts", got)
+	}
+
+	attempts.Store(0)
+	if err := client.Do("POST", "/api/v1/token/pairs", nil, TokenPairsParams{}, nil); err == nil {
+		t.Fatal("Expected a transport error")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Expected a POST not to be retried, got %d attempts", got)
+	}
+
+	attempts.Store(0)
+	client.RetryNonIdempotent = true
+	if err := client.Do("POST", "/api/v1/token/pairs", nil, TokenPairsParams{}, nil); err == nil {
+		t.Fatal("Expected a transport error")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Expected the POST to be retried when opted in, got %d attempts", got)
+	}
+}
+
+func TestRequestRetriesAmbiguousStatus(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	}
+
+	if err := client.Do("POST", "/api/v1/token/pairs", nil, TokenPairsParams{}, nil); err == nil {
+		t.Fatal("Expected a bad gateway error")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Expected a POST answered with 502 to be sent once, got %d attempts", got)
+	}
+
+	attempts.Store(0)
+	if _, err := client.request("GET", "/api/v1/chain/ids", nil); err == nil {
+		t.Fatal("Expected a bad gateway error")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Expected a GET answered with 502 to be retried, got %d attempts", got)
+	}
+
+	attempts.Store(0)
+	client.RetryNonIdempotent = true
+	if err := client.Do("POST", "/api/v1/token/pairs", nil, TokenPairsParams{}, nil); err == nil {
+		t.Fatal("Expected a bad gateway error")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("Expected the POST to be retried when opted in, got %d attempts", got)
+	}
+}
+
+func TestRequestCompression(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected a gzip request body, got %q", r.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read gzip body: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(zr)
+		w.Write([]byte(`{"status":"success","data":` + string(data) + `}`))
+	}))
+	defer server.Close()
+
+	client := &VyperClient{
+		BaseURL:          server.URL,
+		ApiKey:           "test-api-key",
+		HttpClient:       server.Client(),
+		CompressRequests: true,
+	}
+
+	var echoed map[string]string
+	if err := client.Do("PUT", "/api/v1/settings", nil, map[string]string{"theme": "dark"}, &echoed); err != nil {
+		t.Fatalf("Do returned an error: %v", err)
+	}
+	if echoed["theme"] != "dark" {
+		t.Errorf("Expected the decompressed body to round-trip, got %v", echoed)
+	}
+}
@@ -0,0 +1,84 @@
+package vyperclientgo
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// closeWriteTimeout bounds the close frame write when ctx has no deadline.
+const closeWriteTimeout = 5 * time.Second
+
+// Close shuts the connection down gracefully. It sends a close frame, waits
+// for the server's reply to end the read loop, and waits for Listen to return
+// once every queued event has reached the handlers. If ctx expires first, the
+// connection is closed, queued events are discarded and ctx.Err() is
+// returned.
+//
+// No handler runs after Close returns; a handler that is already running is
+// waited for, so Close must not be called from inside one. Subscription
+// handles are never waited for: events that do not fit a handle's channel are
+// dropped, so a handle nobody reads cannot hold Close up.
+func (c *VyperWebsocketClient) Close(ctx context.Context) error {
+	defer c.notifyStateChanges()
+
+	c.mu.Lock()
+	conn := c.Conn
+	if conn == nil || c.closing {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	c.closing = true
+	c.setState(StateClosing, nil)
+
+	done := c.listenDone
+	if done == nil {
+		// Nobody is reading, so read the close reply here.
+		done = make(chan struct{})
+		go drainConn(conn, done)
+	}
+	c.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(closeWriteTimeout)
+	}
+	err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	if err != nil {
+		// Without a close frame there is no reply to wait for.
+		conn.Close()
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		c.discard.Store(true)
+		conn.Close()
+		<-done
+	}
+
+	c.mu.Lock()
+	conn.Close()
+	if c.Conn == conn {
+		c.resetConn()
+	}
+	c.closeHandles()
+	c.closing = false
+	c.setState(StateClosed, nil)
+	c.mu.Unlock()
+
+	return err
+}
+
+// drainConn discards frames until the peer's close reply or a read error.
+func drainConn(conn *websocket.Conn, done chan struct{}) {
+	defer close(done)
+
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package vyperclientgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// burstServer sends count token pairs and then keeps reading so it answers
+// the client's close frame.
+func burstServer(count int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for i := 0; i < count; i++ {
+			data, _ := json.Marshal(TokenPair{MarketId: fmt.Sprintf("market-%d", i)})
+			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestVyperWebsocketClient_CloseDrainsQueue(t *testing.T) {
+	s := burstServer(20)
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.QueueSize = 32
+
+	var delivered, afterClose int32
+	var closed atomic.Bool
+	received := make(chan struct{}, 1)
+	client.SetMessageHandler(func(data interface{}) {
+		if closed.Load() {
+			atomic.AddInt32(&afterClose, 1)
+		}
+		if atomic.AddInt32(&delivered, 1) == 1 {
+			received <- struct{}{}
+		}
+		time.Sleep(2 * time.Millisecond)
+	})
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	listenDone := make(chan error, 1)
+	go func() {
+		listenDone <- client.Listen()
+	}()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	closed.Store(true)
+
+	select {
+	case err := <-listenDone:
+		if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("Expected Listen to end with the close handshake, got %v", err)
+		}
+	default:
+		t.Fatal("Listen was still running after Close returned")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if got := atomic.LoadInt32(&delivered); got != 20 {
+		t.Errorf("Expected all 20 queued events to be delivered, got %d", got)
+	}
+	if got := atomic.LoadInt32(&afterClose); got != 0 {
+		t.Errorf("Expected no handler calls after Close, got %d", got)
+	}
+	if client.State() != StateClosed || client.Conn != nil {
+		t.Errorf("Expected a closed client, got state %s", client.State())
+	}
+}
+
+func TestVyperWebsocketClient_CloseDeadline(t *testing.T) {
+	s := burstServer(10)
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.QueueSize = 16
+
+	var delivered int32
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	client.SetMessageHandler(func(data interface{}) {
+		if atomic.AddInt32(&delivered, 1) == 1 {
+			received <- struct{}{}
+			<-release
+		}
+	})
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	go client.Listen()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	time.AfterFunc(100*time.Millisecond, func() { close(release) })
+
+	start := time.Now()
+	err := client.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+	// The running handler is waited for even after the deadline.
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Close returned before the running handler finished, after %v", elapsed)
+	}
+
+	if got := atomic.LoadInt32(&delivered); got != 1 {
+		t.Errorf("Expected queued events to be discarded, got %d deliveries", got)
+	}
+	if got := client.Stats().Dropped; got == 0 {
+		t.Error("Expected discarded events to be counted as dropped")
+	}
+}
+
+func TestVyperWebsocketClient_CloseWithoutListen(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Close(ctx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if client.State() != StateClosed {
+		t.Errorf("Expected closed, got %s", client.State())
+	}
+
+	if err := client.Close(ctx); err == nil {
+		t.Error("Expected closing twice to fail")
+	}
+	if err := client.Listen(); err == nil {
+		t.Error("Expected Listen after Close to fail")
+	}
+}
+
+func TestVyperWebsocketClient_CloseWithUnreadHandle(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+		for i := 0; i < 200; i++ {
+			data, _ := json.Marshal(TokenPair{MarketId: fmt.Sprintf("market-%d", i), TokenType: "Pumpfun"})
+			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+		// Never answer the close frame, so Close runs into its deadline.
+		time.Sleep(5 * time.Second)
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.QueueSize = 256
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	sub, err := client.WatchTokenTypes(PumpfunTokens)
+	if err != nil {
+		t.Fatalf("Failed to watch token types: %v", err)
+	}
+	go client.Listen()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sub.Events()) < subscriptionBufferSize && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- client.Close(ctx)
+	}()
+	select {
+	case err := <-closed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the deadline to be exceeded, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return with an unread subscription handle")
+	}
+	if client.State() != StateClosed {
+		t.Errorf("Expected closed, got %s", client.State())
+	}
+}
@@ -0,0 +1,257 @@
+package vyperclientgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL      = "https://api.vyper.trade"
+	defaultWebsocketURL = "wss://api.vyper.trade/api/v1/ws"
+	defaultTimeout      = 10 * time.Second
+)
+
+// Config holds the settings shared by the REST and WebSocket clients.
+//
+// LoadConfig starts from the defaults, applies the JSON config file and then
+// the environment, so non-empty environment variables override the file:
+//
+//	VYPER_API_KEY              apiKey
+//	VYPER_BASE_URL             baseUrl
+//	VYPER_WS_URL               websocketUrl
+//	VYPER_TIMEOUT              timeout, e.g. "10s"
+//	VYPER_HANDSHAKE_TIMEOUT    handshakeTimeout
+//	VYPER_MAX_RETRIES          maxRetries
+//	VYPER_RETRY_DELAY          retryDelay
+//	VYPER_REQUESTS_PER_SECOND  requestsPerSecond
+type Config struct {
+	ApiKey            string
+	BaseURL           string
+	WebsocketURL      string
+	Timeout           time.Duration
+	HandshakeTimeout  time.Duration
+	MaxRetries        int
+	RetryDelay        time.Duration
+	RequestsPerSecond float64
+}
+
+// ConfigError lists every problem found while loading a configuration.
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid Vyper configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// configFile is the JSON layout of a config file. Durations are strings
+// such as "500ms" and pointers tell unset keys from zero values.
+type configFile struct {
+	ApiKey            *string  `json:"apiKey"`
+	BaseURL           *string  `json:"baseUrl"`
+	WebsocketURL      *string  `json:"websocketUrl"`
+	Timeout           *string  `json:"timeout"`
+	HandshakeTimeout  *string  `json:"handshakeTimeout"`
+	MaxRetries        *int     `json:"maxRetries"`
+	RetryDelay        *string  `json:"retryDelay"`
+	RequestsPerSecond *float64 `json:"requestsPerSecond"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		BaseURL:      defaultBaseURL,
+		WebsocketURL: defaultWebsocketURL,
+		Timeout:      defaultTimeout,
+	}
+}
+
+// LoadConfig builds a Config from the defaults, the JSON file at path and the
+// environment, in increasing order of precedence. If path is empty, the file
+// named by VYPER_CONFIG_FILE is used, if any.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("VYPER_CONFIG_FILE")
+	}
+
+	cfg := DefaultConfig()
+	var problems []string
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, cfg.applyFile(path, data)...)
+	}
+	problems = append(problems, cfg.applyEnv()...)
+	problems = append(problems, cfg.validate()...)
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) applyFile(path string, data []byte) []string {
+	var file configFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+
+	var problems []string
+	setDuration := func(key string, value *string, target *time.Duration) {
+		if value == nil {
+			return
+		}
+		d, err := time.ParseDuration(*value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: invalid duration %q", path, key, *value))
+			return
+		}
+		*target = d
+	}
+
+	if file.ApiKey != nil {
+		cfg.ApiKey = *file.ApiKey
+	}
+	if file.BaseURL != nil {
+		cfg.BaseURL = *file.BaseURL
+	}
+	if file.WebsocketURL != nil {
+		cfg.WebsocketURL = *file.WebsocketURL
+	}
+	setDuration("timeout", file.Timeout, &cfg.Timeout)
+	setDuration("handshakeTimeout", file.HandshakeTimeout, &cfg.HandshakeTimeout)
+	setDuration("retryDelay", file.RetryDelay, &cfg.RetryDelay)
+	if file.MaxRetries != nil {
+		cfg.MaxRetries = *file.MaxRetries
+	}
+	if file.RequestsPerSecond != nil {
+		cfg.RequestsPerSecond = *file.RequestsPerSecond
+	}
+	return problems
+}
+
+func (cfg *Config) applyEnv() []string {
+	var problems []string
+	invalid := func(name, kind, value string) {
+		problems = append(problems, fmt.Sprintf("%s: invalid %s %q", name, kind, value))
+	}
+
+	if value, ok := lookupEnv("VYPER_API_KEY"); ok {
+		cfg.ApiKey = value
+	}
+	if value, ok := lookupEnv("VYPER_BASE_URL"); ok {
+		cfg.BaseURL = value
+	}
+	if value, ok := lookupEnv("VYPER_WS_URL"); ok {
+		cfg.WebsocketURL = value
+	}
+
+	durations := []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"VYPER_TIMEOUT", &cfg.Timeout},
+		{"VYPER_HANDSHAKE_TIMEOUT", &cfg.HandshakeTimeout},
+		{"VYPER_RETRY_DELAY", &cfg.RetryDelay},
+	}
+	for _, d := range durations {
+		if value, ok := lookupEnv(d.name); ok {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				invalid(d.name, "duration", value)
+				continue
+			}
+			*d.target = parsed
+		}
+	}
+
+	if value, ok := lookupEnv("VYPER_MAX_RETRIES"); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			invalid("VYPER_MAX_RETRIES", "integer", value)
+		} else {
+			cfg.MaxRetries = n
+		}
+	}
+	if value, ok := lookupEnv("VYPER_REQUESTS_PER_SECOND"); ok {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			invalid("VYPER_REQUESTS_PER_SECOND", "number", value)
+		} else {
+			cfg.RequestsPerSecond = n
+		}
+	}
+	return problems
+}
+
+// lookupEnv treats empty variables as unset, so they do not clear values
+// from the config file.
+func lookupEnv(name string) (string, bool) {
+	value := os.Getenv(name)
+	return value, value != ""
+}
+
+func (cfg *Config) validate() []string {
+	var problems []string
+
+	if strings.TrimSpace(cfg.ApiKey) == "" {
+		problems = append(problems, "API key is required (VYPER_API_KEY or apiKey)")
+	}
+	if problem := validateURL("base URL", cfg.BaseURL, "http", "https"); problem != "" {
+		problems = append(problems, problem)
+	}
+	if problem := validateURL("WebSocket URL", cfg.WebsocketURL, "ws", "wss"); problem != "" {
+		problems = append(problems, problem)
+	}
+	if cfg.Timeout < 0 || cfg.HandshakeTimeout < 0 || cfg.RetryDelay < 0 {
+		problems = append(problems, "timeouts and retry delay must not be negative")
+	}
+	if cfg.MaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("max retries must not be negative, got %d", cfg.MaxRetries))
+	}
+	if cfg.RequestsPerSecond < 0 {
+		problems = append(problems, fmt.Sprintf("requests per second must not be negative, got %v", cfg.RequestsPerSecond))
+	}
+	return problems
+}
+
+func validateURL(name, raw string, schemes ...string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Sprintf("invalid %s %q", name, raw)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s %q must use %s", name, raw, strings.Join(schemes, " or "))
+}
+
+func (cfg *Config) NewClient() *VyperClient {
+	client := NewVyperClient(cfg.ApiKey)
+	client.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	client.HttpClient = &http.Client{Timeout: cfg.Timeout}
+	client.MaxRetries = cfg.MaxRetries
+	client.RetryDelay = cfg.RetryDelay
+	client.RequestsPerSecond = cfg.RequestsPerSecond
+	return client
+}
+
+func (cfg *Config) NewWebsocketClient() *VyperWebsocketClient {
+	client := NewVyperWebsocketClient(cfg.ApiKey)
+	client.BaseURL = strings.TrimRight(cfg.WebsocketURL, "/")
+	client.HandshakeTimeout = cfg.HandshakeTimeout
+	return client
+}
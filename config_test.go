@@ -0,0 +1,123 @@
+package vyperclientgo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func clearVyperEnv(t *testing.T) {
+	for _, name := range []string{
+		"VYPER_CONFIG_FILE", "VYPER_API_KEY", "VYPER_BASE_URL", "VYPER_WS_URL", "VYPER_TIMEOUT",
+		"VYPER_HANDSHAKE_TIMEOUT", "VYPER_MAX_RETRIES", "VYPER_RETRY_DELAY", "VYPER_REQUESTS_PER_SECOND",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "vyper.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	clearVyperEnv(t)
+	path := writeConfigFile(t, `{
+		"apiKey": "file-key",
+		"baseUrl": "https://file.example.com/",
+		"timeout": "3s",
+		"maxRetries": 2,
+		"requestsPerSecond": 5
+	}`)
+	t.Setenv("VYPER_CONFIG_FILE", path)
+	t.Setenv("VYPER_API_KEY", "env-key")
+	t.Setenv("VYPER_RETRY_DELAY", "250ms")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+
+	expected := &Config{
+		ApiKey:            "env-key",
+		BaseURL:           "https://file.example.com/",
+		WebsocketURL:      defaultWebsocketURL,
+		Timeout:           3 * time.Second,
+		MaxRetries:        2,
+		RetryDelay:        250 * time.Millisecond,
+		RequestsPerSecond: 5,
+	}
+	if *cfg != *expected {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+
+	client := cfg.NewClient()
+	if client.ApiKey != "env-key" || client.BaseURL != "https://file.example.com" || client.HttpClient.Timeout != 3*time.Second {
+		t.Errorf("Unexpected REST client: %+v", client)
+	}
+	if client.MaxRetries != 2 || client.RetryDelay != 250*time.Millisecond || client.RequestsPerSecond != 5 {
+		t.Errorf("Unexpected REST client retry settings: %+v", client)
+	}
+
+	wsClient := cfg.NewWebsocketClient()
+	if wsClient.ApiKey != "env-key" || wsClient.BaseURL != defaultWebsocketURL {
+		t.Errorf("Unexpected WebSocket client: %+v", wsClient)
+	}
+}
+
+func TestLoadConfig_EnvOnly(t *testing.T) {
+	clearVyperEnv(t)
+	t.Setenv("VYPER_API_KEY", "env-key")
+	t.Setenv("VYPER_WS_URL", "ws://localhost:8080/ws")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+	if cfg.BaseURL != defaultBaseURL || cfg.Timeout != defaultTimeout || cfg.WebsocketURL != "ws://localhost:8080/ws" {
+		t.Errorf("Expected defaults with the env override, got %+v", cfg)
+	}
+}
+
+func TestLoadConfig_ValidationErrors(t *testing.T) {
+	clearVyperEnv(t)
+	path := writeConfigFile(t, `{"baseUrl": "ftp://example.com", "timeout": "soon", "maxRetries": -1}`)
+	t.Setenv("VYPER_REQUESTS_PER_SECOND", "fast")
+
+	_, err := LoadConfig(path)
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Expected a ConfigError, got %v", err)
+	}
+
+	for _, want := range []string{"API key is required", "timeout: invalid duration", "VYPER_REQUESTS_PER_SECOND", "must use http or https", "max retries"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+
+	if _, err := LoadConfig(writeConfigFile(t, `{"apiKey": "k", "baseURL": "https://example.com"}`)); err == nil {
+		t.Error("Expected an unknown config key to be rejected")
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file error, got %v", err)
+	}
+}
+
+func TestVyperClient_RequestsPerSecond(t *testing.T) {
+	client := &VyperClient{RequestsPerSecond: 50}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		client.waitForSlot()
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected 3 requests at 50/s to take at least 40ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,199 @@
+package vyperclientgo
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type ControlMessageKind string
+
+const (
+	ControlAck       ControlMessageKind = "ack"
+	ControlError     ControlMessageKind = "error"
+	ControlRateLimit ControlMessageKind = "rate-limit"
+	ControlNotice    ControlMessageKind = "notice"
+)
+
+// ControlMessage is a frame sent by the server that is not market data, such
+// as a subscription acknowledgement, an error or a rate-limit warning.
+type ControlMessage struct {
+	Kind       ControlMessageKind
+	FeedType   FeedType
+	Type       string
+	Status     string
+	Message    string
+	Action     string
+	Types      []string
+	Wallets    []string
+	Code       int
+	RetryAfter float64
+	Raw        json.RawMessage
+}
+
+// Err returns the message as an error for error and rate-limit frames, and
+// nil for acknowledgements and notices. Rate-limit frames are returned as
+// *WebsocketRateLimitError.
+func (m *ControlMessage) Err() error {
+	base := VyperWebsocketError{
+		Message:        m.Message,
+		StatusCode:     m.Code,
+		ConnectionInfo: m.FeedType,
+	}
+	switch m.Kind {
+	case ControlError:
+		return &base
+	case ControlRateLimit:
+		return &WebsocketRateLimitError{VyperWebsocketError: base, RetryAfter: m.RetryAfter}
+	}
+	return nil
+}
+
+type ControlHandler func(*ControlMessage)
+
+// controlFields holds the keys that only appear on control frames. They are
+// read leniently, so a key of an unexpected type never fails a frame: a string
+// code such as "RATE_LIMITED" is kept in CodeText and a message given as an
+// object is reduced to its own "message" or its JSON text.
+type controlFields struct {
+	Type       string
+	Event      string
+	Status     string
+	Message    string
+	Error      json.RawMessage
+	Action     string
+	Types      []string
+	Wallets    []string
+	Code       int
+	CodeText   string
+	RetryAfter float64
+}
+
+// decodeControlMessage is the second pass over a frame that did not decode
+// into an event with the ID named by idKey. A frame that has the ID but failed
+// to decode is a broken event, and eventErr is returned for it.
+func decodeControlMessage(feedType FeedType, message []byte, idKey string, eventErr error) (interface{}, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return nil, err
+	}
+	if eventErr != nil && rawText(fields[idKey]) != "" {
+		return nil, eventErr
+	}
+
+	f := controlFields{
+		Type:       rawText(fields["type"]),
+		Event:      rawText(fields["event"]),
+		Status:     rawText(fields["status"]),
+		Message:    rawText(fields["message"]),
+		Error:      fields["error"],
+		Action:     rawText(fields["action"]),
+		Types:      rawStrings(fields["types"]),
+		Wallets:    rawStrings(fields["wallets"]),
+		CodeText:   rawText(fields["code"]),
+		RetryAfter: rawFloat(fields["retryAfter"]),
+	}
+	if code, err := strconv.Atoi(f.CodeText); err == nil {
+		f.Code = code
+	}
+	return f.toControlMessage(feedType, message), nil
+}
+
+func (f *controlFields) toControlMessage(feedType FeedType, message []byte) *ControlMessage {
+	control := &ControlMessage{
+		Kind:       f.kind(),
+		FeedType:   feedType,
+		Type:       f.Type,
+		Status:     f.Status,
+		Message:    f.Message,
+		Action:     f.Action,
+		Types:      f.Types,
+		Wallets:    f.Wallets,
+		Code:       f.Code,
+		RetryAfter: f.RetryAfter,
+		Raw:        append(json.RawMessage(nil), message...),
+	}
+	if control.Type == "" {
+		control.Type = f.Event
+	}
+	if text := f.errorText(); text != "" {
+		control.Message = text
+	}
+	return control
+}
+
+func (f *controlFields) kind() ControlMessageKind {
+	label := strings.ToLower(f.Type + " " + f.Event + " " + f.Status)
+	if f.Code == 0 {
+		label += " " + strings.ToLower(f.CodeText)
+	}
+	compact := strings.NewReplacer("_", "", "-", "", " ", "").Replace(label)
+
+	switch {
+	case f.Code == 429 || strings.Contains(compact, "ratelimit") || strings.Contains(compact, "throttl"):
+		return ControlRateLimit
+	case f.hasError() || strings.Contains(label, "error"):
+		return ControlError
+	case strings.Contains(label, "subscri") || strings.Contains(label, "ack") ||
+		f.Status == "success" || f.Status == "ok":
+		return ControlAck
+	}
+	return ControlNotice
+}
+
+func (f *controlFields) hasError() bool {
+	return len(f.Error) > 0 && string(f.Error) != "null" && string(f.Error) != "false"
+}
+
+// errorText extracts a message from the "error" key, which may be a string
+// or an object with its own "message".
+func (f *controlFields) errorText() string {
+	if !f.hasError() {
+		return ""
+	}
+	return rawText(f.Error)
+}
+
+// rawText reads a control value as text: strings as they are, objects as
+// their "message" if they have one and anything else as its JSON.
+func rawText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var detail struct {
+		Message json.RawMessage `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &detail); err == nil && len(detail.Message) > 0 {
+		return rawText(detail.Message)
+	}
+	return string(raw)
+}
+
+func rawFloat(raw json.RawMessage) float64 {
+	n, err := strconv.ParseFloat(rawText(raw), 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func rawStrings(raw json.RawMessage) []string {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if text := rawText(item); text != "" {
+			values = append(values, text)
+		}
+	}
+	return values
+}
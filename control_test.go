@@ -0,0 +1,155 @@
+package vyperclientgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDecodeMessage_ControlFrames(t *testing.T) {
+	cases := []struct {
+		name     string
+		feedType FeedType
+		frame    string
+		kind     ControlMessageKind
+		message  string
+	}{
+		{"ack", TokenEvents, `{"status":"subscribed","action":"subscribe","types":["PumpfunTokens"]}`, ControlAck, ""},
+		{"error string", WalletEvents, `{"error":"invalid wallet address"}`, ControlError, "invalid wallet address"},
+		{"error object", TokenEvents, `{"type":"error","error":{"message":"unknown type"}}`, ControlError, "unknown type"},
+		{"rate limit", WalletEvents, `{"type":"rate_limit","message":"slow down","retryAfter":2.5}`, ControlRateLimit, "slow down"},
+		{"rate limit code", TokenEvents, `{"code":429,"message":"too many requests"}`, ControlRateLimit, "too many requests"},
+		{"notice", MigrationEvents, `{"type":"maintenance","message":"restarting soon"}`, ControlNotice, "restarting soon"},
+		{"string code", TokenEvents, `{"type":"error","code":"RATE_LIMITED","message":"slow down"}`, ControlRateLimit, "slow down"},
+		{"string error code", WalletEvents, `{"code":"INTERNAL_ERROR","message":"try again"}`, ControlError, "try again"},
+		{"string retry after", WalletEvents, `{"type":"rate_limit","retryAfter":"5","message":"wait"}`, ControlRateLimit, "wait"},
+		{"object message", MigrationEvents, `{"type":"error","message":{"message":"unknown type"}}`, ControlError, "unknown type"},
+		{"object message without text", TokenEvents, `{"type":"maintenance","message":{"until":"12:00"}}`, ControlNotice, `{"until":"12:00"}`},
+		{"empty object", TokenEvents, `{}`, ControlNotice, ""},
+		{"heartbeat", WalletEvents, `{"heartbeat":1}`, ControlNotice, ""},
+		{"pong", MigrationEvents, `{"pong":true}`, ControlNotice, ""},
+		{"subscribed list", TokenEvents, `{"subscribed":["PumpfunTokens"]}`, ControlNotice, ""},
+		{"wallet frame without transaction", WalletEvents, `{"subscribed":["wallet-1"]}`, ControlNotice, ""},
+	}
+
+	for _, tc := range cases {
+		data, err := decodeMessage(tc.feedType, []byte(tc.frame))
+		if err != nil {
+			t.Fatalf("%s: decodeMessage returned an error: %v", tc.name, err)
+		}
+		control, ok := data.(*ControlMessage)
+		if !ok {
+			t.Fatalf("%s: expected a control message, got %T", tc.name, data)
+		}
+		if control.Kind != tc.kind {
+			t.Errorf("%s: expected kind %s, got %s", tc.name, tc.kind, control.Kind)
+		}
+		if control.Message != tc.message {
+			t.Errorf("%s: expected message %q, got %q", tc.name, tc.message, control.Message)
+		}
+		if string(control.Raw) != tc.frame {
+			t.Errorf("%s: expected raw frame to be kept, got %s", tc.name, control.Raw)
+		}
+		isFailure := tc.kind == ControlError || tc.kind == ControlRateLimit
+		if (control.Err() != nil) != isFailure {
+			t.Errorf("%s: unexpected Err() result: %v", tc.name, control.Err())
+		}
+	}
+}
+
+func TestDecodeMessage_LenientControlFields(t *testing.T) {
+	data, err := decodeMessage(WalletEvents, []byte(`{"type":"rate_limit","retryAfter":"5","code":"429"}`))
+	if err != nil {
+		t.Fatalf("decodeMessage returned an error: %v", err)
+	}
+	control := data.(*ControlMessage)
+	if control.RetryAfter != 5 || control.Code != 429 {
+		t.Errorf("Expected RetryAfter 5 and code 429 from strings, got %v and %d", control.RetryAfter, control.Code)
+	}
+
+	// A frame with an event ID that does not decode is a broken event, not
+	// a control frame.
+	if _, err := decodeMessage(TokenEvents, []byte(`{"marketId":"market-1","name":5}`)); err == nil {
+		t.Error("Expected an error for a malformed token pair")
+	}
+	if _, err := decodeMessage(TokenEvents, []byte(`[1,2]`)); err == nil {
+		t.Error("Expected an error for a frame that is not an object")
+	}
+}
+
+func TestDecodeMessage_EventsAreNotControlFrames(t *testing.T) {
+	data, err := decodeMessage(TokenEvents, []byte(`{"marketId":"test-market","name":"Test Token"}`))
+	if err != nil {
+		t.Fatalf("decodeMessage returned an error: %v", err)
+	}
+	if _, ok := data.(*TokenPair); !ok {
+		t.Errorf("Expected a TokenPair, got %T", data)
+	}
+
+	data, err = decodeMessage(WalletEvents, []byte(`{"transactionId":"tx-1","signer":"wallet-1"}`))
+	if err != nil {
+		t.Fatalf("decodeMessage returned an error: %v", err)
+	}
+	if _, ok := data.(*ChainAction); !ok {
+		t.Errorf("Expected a ChainAction, got %T", data)
+	}
+}
+
+func TestVyperWebsocketClient_ListenRoutesControlFrames(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.TextMessage, []byte(`{"status":"subscribed","action":"subscribe","types":["PumpfunTokens"]}`))
+		data, _ := json.Marshal(TokenPair{MarketId: "test-market"})
+		c.WriteMessage(websocket.TextMessage, data)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	var events []interface{}
+	var controls []*ControlMessage
+	client.SetMessageHandler(func(data interface{}) {
+		events = append(events, data)
+	})
+	client.SetControlHandler(func(control *ControlMessage) {
+		controls = append(controls, control)
+	})
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Listen()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Listen to return")
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 market event, got %d: %+v", len(events), events)
+	}
+	if pair, ok := events[0].(*TokenPair); !ok || pair.MarketId != "test-market" {
+		t.Errorf("Unexpected market event: %+v", events[0])
+	}
+	if len(controls) != 1 || controls[0].Kind != ControlAck || controls[0].FeedType != TokenEvents {
+		t.Errorf("Unexpected control messages: %+v", controls)
+	}
+}
@@ -0,0 +1,177 @@
+package vyperclientgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCommandCredentialsTTL     = 5 * time.Minute
+	defaultCommandCredentialsTimeout = 10 * time.Second
+)
+
+// CredentialsProvider supplies the API key. The clients ask for it on every
+// REST request and every WebSocket connect, so a rotated key is picked up
+// without restarting them.
+type CredentialsProvider interface {
+	ApiKey() (string, error)
+}
+
+// StaticCredentials always returns the same key.
+type StaticCredentials string
+
+func (s StaticCredentials) ApiKey() (string, error) {
+	return string(s), nil
+}
+
+// EnvCredentials reads the key from an environment variable on every call.
+type EnvCredentials struct {
+	Name string
+}
+
+func (e EnvCredentials) ApiKey() (string, error) {
+	key := strings.TrimSpace(os.Getenv(e.Name))
+	if key == "" {
+		return "", fmt.Errorf("environment variable %s is not set", e.Name)
+	}
+	return key, nil
+}
+
+// FileCredentials reads the key from a file and reads it again whenever the
+// file's modification time or size changes.
+type FileCredentials struct {
+	Path string
+
+	mu      sync.Mutex
+	key     string
+	modTime time.Time
+	size    int64
+}
+
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{Path: path}
+}
+
+func (f *FileCredentials) ApiKey() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "", err
+	}
+	if f.key != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.key, nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("credentials file %s is empty", f.Path)
+	}
+
+	f.key = key
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return key, nil
+}
+
+// CommandCredentials runs an external command, such as a secrets manager
+// CLI, and uses its trimmed standard output as the key. The key is cached for
+// TTL; NewCommandCredentials sets it to five minutes and zero runs the
+// command on every call. A run that takes longer than Timeout, ten seconds
+// if zero, is killed, as clients may be waiting on it while connecting.
+type CommandCredentials struct {
+	Command []string
+	TTL     time.Duration
+	Timeout time.Duration
+
+	mu        sync.Mutex
+	key       string
+	fetchedAt time.Time
+}
+
+func NewCommandCredentials(name string, args ...string) *CommandCredentials {
+	return &CommandCredentials{
+		Command: append([]string{name}, args...),
+		TTL:     defaultCommandCredentialsTTL,
+		Timeout: defaultCommandCredentialsTimeout,
+	}
+}
+
+func (c *CommandCredentials) ApiKey() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key != "" && time.Since(c.fetchedAt) < c.TTL {
+		return c.key, nil
+	}
+	if len(c.Command) == 0 {
+		return "", fmt.Errorf("no credentials command configured")
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandCredentialsTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("credentials command %s: %w after %v", c.Command[0], ctx.Err(), timeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("credentials command %s: %w: %s", c.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", fmt.Errorf("credentials command %s printed no key", c.Command[0])
+	}
+
+	c.key = key
+	c.fetchedAt = time.Now()
+	return key, nil
+}
+
+// resolveApiKey returns the key from provider, or apiKey if there is none.
+func resolveApiKey(provider CredentialsProvider, apiKey string) (string, error) {
+	if provider == nil {
+		return apiKey, nil
+	}
+	key, err := provider.ApiKey()
+	if err != nil {
+		return "", fmt.Errorf("resolving API key: %w", err)
+	}
+	return key, nil
+}
+
+// credentialsRotated reports whether the provider now returns a different key
+// than the one the last Connect used, so an authentication failure may be
+// worth retrying.
+func (c *VyperWebsocketClient) credentialsRotated() bool {
+	if c.Credentials == nil {
+		return false
+	}
+	key, err := c.Credentials.ApiKey()
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return key != c.dialedKey
+}
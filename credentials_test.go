@@ -0,0 +1,167 @@
+package vyperclientgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func writeKeyFile(t *testing.T, path, key string, modTime time.Time) {
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set key file time: %v", err)
+	}
+}
+
+func TestCredentialsProviders(t *testing.T) {
+	if key, _ := StaticCredentials("static-key").ApiKey(); key != "static-key" {
+		t.Errorf("Expected the static key, got %q", key)
+	}
+
+	t.Setenv("VYPER_TEST_KEY", " env-key ")
+	if key, err := (EnvCredentials{Name: "VYPER_TEST_KEY"}).ApiKey(); err != nil || key != "env-key" {
+		t.Errorf("Expected env-key, got %q, %v", key, err)
+	}
+	t.Setenv("VYPER_TEST_KEY", "")
+	if _, err := (EnvCredentials{Name: "VYPER_TEST_KEY"}).ApiKey(); err == nil {
+		t.Error("Expected an error for an unset variable")
+	}
+
+	path := filepath.Join(t.TempDir(), "api-key")
+	base := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "file-key-1", base)
+
+	file := NewFileCredentials(path)
+	if key, err := file.ApiKey(); err != nil || key != "file-key-1" {
+		t.Fatalf("Expected file-key-1, got %q, %v", key, err)
+	}
+	writeKeyFile(t, path, "file-key-2", base.Add(time.Minute))
+	if key, err := file.ApiKey(); err != nil || key != "file-key-2" {
+		t.Errorf("Expected the rotated key, got %q, %v", key, err)
+	}
+
+	command := NewCommandCredentials("echo", "command-key-1")
+	if key, err := command.ApiKey(); err != nil || key != "command-key-1" {
+		t.Fatalf("Expected command-key-1, got %q, %v", key, err)
+	}
+	command.Command = []string{"echo", "command-key-2"}
+	if key, _ := command.ApiKey(); key != "command-key-1" {
+		t.Errorf("Expected the cached key within the TTL, got %q", key)
+	}
+	command.TTL = 0
+	if key, _ := command.ApiKey(); key != "command-key-2" {
+		t.Errorf("Expected the command to run again, got %q", key)
+	}
+
+	if _, err := NewCommandCredentials("false").ApiKey(); err == nil {
+		t.Error("Expected an error from a failing command")
+	}
+
+	slow := NewCommandCredentials("sleep", "5")
+	slow.Timeout = 50 * time.Millisecond
+	start := time.Now()
+	if _, err := slow.ApiKey(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the slow command to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the slow command to be killed, took %v", elapsed)
+	}
+}
+
+func TestVyperClient_Credentials(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		w.Write([]byte(`{"status":"success","data":{}}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "api-key")
+	base := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "key-1", base)
+
+	client := &VyperClient{
+		BaseURL:     server.URL,
+		ApiKey:      "unused",
+		Credentials: NewFileCredentials(path),
+		HttpClient:  server.Client(),
+	}
+
+	if _, err := client.GetChainIds(); err != nil {
+		t.Fatalf("GetChainIds returned an error: %v", err)
+	}
+	writeKeyFile(t, path, "key-2", base.Add(time.Minute))
+	if _, err := client.GetChainIds(); err != nil {
+		t.Fatalf("GetChainIds returned an error: %v", err)
+	}
+
+	if strings.Join(keys, ",") != "key-1,key-2" {
+		t.Errorf("Expected the rotated key on the second request, got %v", keys)
+	}
+
+	client.Credentials = EnvCredentials{Name: "VYPER_TEST_UNSET_KEY"}
+	if _, err := client.GetChainIds(); err == nil || !strings.Contains(err.Error(), "resolving API key") {
+		t.Errorf("Expected a credentials error, got %v", err)
+	}
+}
+
+func TestVyperMultiFeedClient_RotatedKeyReconnects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	base := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "old-key", base)
+
+	accepted := make(chan string, 4)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("apiKey")
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		accepted <- key
+		if key == "old-key" {
+			// Revoke the old key once the new one has been issued.
+			writeKeyFile(t, path, "new-key", base.Add(time.Minute))
+			c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4001, "api key revoked"))
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("unused")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.Credentials = NewFileCredentials(path)
+	client.ReconnectDelay = 10 * time.Millisecond
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	for _, want := range []string{"old-key", "new-key"} {
+		select {
+		case key := <-accepted:
+			if key != want {
+				t.Fatalf("Expected a connection with %s, got %s", want, key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for a connection with %s", want)
+		}
+	}
+}
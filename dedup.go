@@ -0,0 +1,142 @@
+package vyperclientgo
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDedupMaxEntries = 100000
+	defaultDedupTTL        = 5 * time.Minute
+)
+
+type dedupEntry struct {
+	key  string
+	seen time.Time
+}
+
+// Deduplicator drops events that were already delivered within a bounded
+// window. Wallet actions are keyed on their transaction and market, token
+// updates on their market plus the fields that change with every update. A
+// single Deduplicator may be shared by several clients to suppress events
+// delivered by overlapping connections.
+//
+// A zero MaxEntries or TTL uses the defaults of 100000 entries and five
+// minutes, so a Deduplicator may also be created as a struct literal.
+type Deduplicator struct {
+	MaxEntries int
+	TTL        time.Duration
+
+	mu         sync.Mutex
+	keys       map[string]struct{}
+	entries    []dedupEntry
+	head       int
+	duplicates atomic.Uint64
+}
+
+func NewDeduplicator(maxEntries int, ttl time.Duration) *Deduplicator {
+	if maxEntries <= 0 {
+		maxEntries = defaultDedupMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+	return &Deduplicator{
+		MaxEntries: maxEntries,
+		TTL:        ttl,
+	}
+}
+
+// Seen records the event and reports whether it was already seen within the
+// window. Events without a usable key are never treated as duplicates.
+func (d *Deduplicator) Seen(data interface{}) bool {
+	key := dedupKey(data)
+	if key == "" {
+		return false
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.keys == nil {
+		d.keys = make(map[string]struct{})
+	}
+	d.evict(now)
+
+	if _, ok := d.keys[key]; ok {
+		d.duplicates.Add(1)
+		return true
+	}
+
+	d.keys[key] = struct{}{}
+	d.entries = append(d.entries, dedupEntry{key: key, seen: now})
+	return false
+}
+
+func (d *Deduplicator) Duplicates() uint64 {
+	return d.duplicates.Load()
+}
+
+func (d *Deduplicator) evict(now time.Time) {
+	maxEntries, ttl := d.MaxEntries, d.TTL
+	if maxEntries <= 0 {
+		maxEntries = defaultDedupMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+
+	for d.head < len(d.entries) {
+		oldest := d.entries[d.head]
+		if len(d.keys) < maxEntries && now.Sub(oldest.seen) < ttl {
+			break
+		}
+		delete(d.keys, oldest.key)
+		d.entries[d.head] = dedupEntry{}
+		d.head++
+	}
+
+	// Compact once the evicted prefix dominates the slice.
+	if d.head > 0 && d.head >= len(d.entries)/2 {
+		d.entries = append(d.entries[:0], d.entries[d.head:]...)
+		d.head = 0
+	}
+}
+
+func dedupKey(data interface{}) string {
+	switch event := data.(type) {
+	case *ChainAction:
+		if event.TransactionId == "" {
+			return ""
+		}
+		return "action:" + event.TransactionId + ":" + event.MarketId
+	case *TokenPair:
+		if event.MarketId == "" {
+			return ""
+		}
+
+		var b strings.Builder
+		b.WriteString("pair:")
+		b.WriteString(event.MarketId)
+		for _, n := range []int{event.TransactionCount, event.BuyTxnCount, event.SellTxnCount} {
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(n))
+		}
+		for _, f := range []float64{event.TokenPriceUsd, event.TokenLiquidityUsd, event.VolumeUsd, event.PooledAsset, event.PooledToken} {
+			b.WriteByte(':')
+			b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		b.WriteByte(':')
+		b.WriteString(event.MigratedMarketId)
+		if event.IsMigrated != nil {
+			b.WriteString(strconv.FormatBool(*event.IsMigrated))
+		}
+		return b.String()
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package vyperclientgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDeduplicator_Keys(t *testing.T) {
+	d := NewDeduplicator(10, time.Minute)
+
+	action := &ChainAction{TransactionId: "tx-1", MarketId: "market-1", Signer: "wallet-1"}
+	if d.Seen(action) {
+		t.Error("First action reported as duplicate")
+	}
+	if !d.Seen(&ChainAction{TransactionId: "tx-1", MarketId: "market-1", Signer: "wallet-1"}) {
+		t.Error("Repeated action not reported as duplicate")
+	}
+	if d.Seen(&ChainAction{TransactionId: "tx-1", MarketId: "market-2"}) {
+		t.Error("Action on another market in the same transaction reported as duplicate")
+	}
+
+	pair := &TokenPair{MarketId: "market-1", TransactionCount: 10, TokenPriceUsd: 1.5}
+	if d.Seen(pair) {
+		t.Error("First token update reported as duplicate")
+	}
+	if !d.Seen(&TokenPair{MarketId: "market-1", TransactionCount: 10, TokenPriceUsd: 1.5}) {
+		t.Error("Repeated token update not reported as duplicate")
+	}
+	if d.Seen(&TokenPair{MarketId: "market-1", TransactionCount: 11, TokenPriceUsd: 1.6}) {
+		t.Error("New token update reported as duplicate")
+	}
+
+	if d.Seen(&ChainAction{}) || d.Seen(&ChainAction{}) {
+		t.Error("Events without a key must never be duplicates")
+	}
+
+	if got := d.Duplicates(); got != 2 {
+		t.Errorf("Expected 2 duplicates, got %d", got)
+	}
+}
+
+func TestDeduplicator_Window(t *testing.T) {
+	d := NewDeduplicator(2, time.Minute)
+
+	d.Seen(&ChainAction{TransactionId: "tx-1"})
+	d.Seen(&ChainAction{TransactionId: "tx-2"})
+	d.Seen(&ChainAction{TransactionId: "tx-3"})
+
+	// tx-1 was evicted to keep the window at two entries.
+	if d.Seen(&ChainAction{TransactionId: "tx-1"}) {
+		t.Error("Evicted key reported as duplicate")
+	}
+	if len(d.keys) > 2 {
+		t.Errorf("Expected at most 2 keys, got %d", len(d.keys))
+	}
+
+	d = NewDeduplicator(10, 20*time.Millisecond)
+	d.Seen(&ChainAction{TransactionId: "tx-1"})
+	time.Sleep(30 * time.Millisecond)
+	if d.Seen(&ChainAction{TransactionId: "tx-1"}) {
+		t.Error("Expired key reported as duplicate")
+	}
+}
+
+func TestDeduplicator_ZeroValue(t *testing.T) {
+	d := &Deduplicator{MaxEntries: 10, TTL: time.Minute}
+	if d.Seen(&ChainAction{TransactionId: "tx-1"}) {
+		t.Error("First event reported as duplicate")
+	}
+	if !d.Seen(&ChainAction{TransactionId: "tx-1"}) {
+		t.Error("Expected the repeated event to be a duplicate")
+	}
+
+	// Zero fields fall back to the defaults instead of evicting every key.
+	var zero Deduplicator
+	zero.Seen(&ChainAction{TransactionId: "tx-1"})
+	if !zero.Seen(&ChainAction{TransactionId: "tx-1"}) {
+		t.Error("Expected a zero Deduplicator to use the default window")
+	}
+}
+
+func TestVyperWebsocketClient_DeduplicatesAcrossConnections(t *testing.T) {
+	frame, _ := json.Marshal(ChainAction{Signer: "wallet-1", TransactionId: "tx-1", MarketId: "market-1"})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.TextMessage, frame)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	dedup := NewDeduplicator(100, time.Minute)
+	delivered := 0
+
+	var clients []*VyperWebsocketClient
+	for i := 0; i < 2; i++ {
+		client := NewVyperWebsocketClient("test-api-key")
+		client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+		client.Deduplicator = dedup
+		client.SetMessageHandler(func(data interface{}) {
+			delivered++
+		})
+
+		if err := client.Connect(WalletEvents); err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		client.Listen()
+		client.Disconnect()
+		clients = append(clients, client)
+	}
+
+	if delivered != 1 {
+		t.Errorf("Expected the action to be delivered once, got %d", delivered)
+	}
+	if got := clients[1].Stats().Duplicates; got != 1 {
+		t.Errorf("Expected the second client to count 1 duplicate, got %d", got)
+	}
+	if got := dedup.Duplicates(); got != 1 {
+		t.Errorf("Expected the deduplicator to count 1 duplicate, got %d", got)
+	}
+}
@@ -0,0 +1,37 @@
+package vyperclientgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialer builds the dialer for Connect. It starts from Dialer, or from
+// websocket.DefaultDialer when none is set, and applies the proxy, TLS,
+// handshake timeout and compression settings on top of it.
+func (c *VyperWebsocketClient) dialer() (*websocket.Dialer, error) {
+	d := *websocket.DefaultDialer
+	if c.Dialer != nil {
+		d = *c.Dialer
+	}
+
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		d.Proxy = http.ProxyURL(proxyURL)
+	}
+	if c.TLSConfig != nil {
+		d.TLSClientConfig = c.TLSConfig
+	}
+	if c.HandshakeTimeout > 0 {
+		d.HandshakeTimeout = c.HandshakeTimeout
+	}
+	if c.EnableCompression {
+		d.EnableCompression = true
+	}
+	return &d, nil
+}
@@ -0,0 +1,120 @@
+package vyperclientgo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVyperWebsocketClient_TLSConfig(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "wss" + strings.TrimPrefix(s.URL, "https")
+	client.HandshakeTimeout = 5 * time.Second
+
+	// The test server's certificate is self-signed, so the default roots
+	// must reject it.
+	if err := client.Connect(TokenEvents); err == nil {
+		client.Disconnect()
+		t.Fatal("Expected connecting without the test CA to fail")
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+	client.TLSConfig = &tls.Config{RootCAs: roots}
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect with pinned CA: %v", err)
+	}
+	client.Disconnect()
+}
+
+func TestVyperWebsocketClient_HeaderAndCompression(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		echo(w, r)
+	}))
+	defer s.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Certificate())
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "wss" + strings.TrimPrefix(s.URL, "https")
+	client.TLSConfig = &tls.Config{RootCAs: roots}
+	client.Header = http.Header{"X-Trace-Id": []string{"trace-1"}}
+	client.EnableCompression = true
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	header := <-headers
+	if got := header.Get("X-Trace-Id"); got != "trace-1" {
+		t.Errorf("Expected X-Trace-Id header, got %q", got)
+	}
+	if got := header.Get("Sec-Websocket-Extensions"); !strings.Contains(got, "permessage-deflate") {
+		t.Errorf("Expected permessage-deflate to be offered, got %q", got)
+	}
+}
+
+func TestVyperWebsocketClient_ProxyURL(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	var tunnels int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		atomic.AddInt32(&tunnels, 1)
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	}))
+	defer proxy.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ProxyURL = proxy.URL
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect through proxy: %v", err)
+	}
+	defer client.Disconnect()
+
+	if got := atomic.LoadInt32(&tunnels); got != 1 {
+		t.Errorf("Expected 1 proxy tunnel, got %d", got)
+	}
+
+	client.ProxyURL = "://bad"
+	if _, err := client.dialer(); err == nil {
+		t.Error("Expected an error for an invalid proxy URL")
+	}
+}
@@ -0,0 +1,55 @@
+package vyperclientgo
+
+import "hash/fnv"
+
+const defaultWorkerQueueSize = 64
+
+// dispatcher spreads events over ordered worker queues. Events with the same
+// key always go to the same worker, so they are handled in arrival order
+// while events for different markets or wallets run concurrently.
+type dispatcher struct {
+	queues []*eventQueue
+}
+
+func newDispatcher(workers, size int, policy OverflowPolicy, counters *listenerCounters, deliver func(*Envelope)) *dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 1 {
+		size = defaultWorkerQueueSize
+	}
+
+	d := &dispatcher{queues: make([]*eventQueue, workers)}
+	for i := range d.queues {
+		d.queues[i] = newEventQueue(size, policy, counters, deliver)
+	}
+	return d
+}
+
+func (d *dispatcher) push(env *Envelope) bool {
+	if len(d.queues) == 1 {
+		return d.queues[0].push(env)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(dispatchKey(env.Data)))
+	return d.queues[h.Sum32()%uint32(len(d.queues))].push(env)
+}
+
+func (d *dispatcher) close() {
+	for _, q := range d.queues {
+		q.close()
+	}
+}
+
+// dispatchKey returns the ordering key of an event: the market for token
+// pairs and the signer for wallet actions.
+func dispatchKey(data interface{}) string {
+	switch event := data.(type) {
+	case *TokenPair:
+		return event.MarketId
+	case *ChainAction:
+		return event.Signer
+	}
+	return ""
+}
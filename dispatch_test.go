@@ -0,0 +1,83 @@
+package vyperclientgo
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcher_PreservesPerKeyOrder(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]uint64)
+
+	d := newDispatcher(4, 8, OverflowBlock, &listenerCounters{}, func(env *Envelope) {
+		action := env.Data.(*ChainAction)
+
+		mu.Lock()
+		received[action.Signer] = append(received[action.Signer], env.Sequence)
+		mu.Unlock()
+	})
+
+	signers := []string{"wallet-a", "wallet-b", "wallet-c", "wallet-d", "wallet-e"}
+	for i := 0; i < 500; i++ {
+		signer := signers[i%len(signers)]
+		d.push(&Envelope{Sequence: uint64(i), Data: &ChainAction{Signer: signer}})
+	}
+	d.close()
+
+	for _, signer := range signers {
+		seqs := received[signer]
+		if len(seqs) != 100 {
+			t.Fatalf("Expected 100 events for %s, got %d", signer, len(seqs))
+		}
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] < seqs[i-1] {
+				t.Fatalf("Events for %s out of order: %v", signer, seqs)
+			}
+		}
+	}
+}
+
+func TestDispatcher_RunsKeysConcurrently(t *testing.T) {
+	workers := 4
+	workerFor := func(key string) uint32 {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		return h.Sum32() % uint32(workers)
+	}
+
+	// Find two markets that land on different workers.
+	slow := "market-0"
+	fast := ""
+	for i := 1; fast == ""; i++ {
+		candidate := fmt.Sprintf("market-%d", i)
+		if workerFor(candidate) != workerFor(slow) {
+			fast = candidate
+		}
+	}
+
+	release := make(chan struct{})
+	fastDone := make(chan struct{})
+	d := newDispatcher(workers, 8, OverflowBlock, &listenerCounters{}, func(env *Envelope) {
+		switch env.Data.(*TokenPair).MarketId {
+		case slow:
+			<-release
+		case fast:
+			close(fastDone)
+		}
+	})
+
+	d.push(&Envelope{Data: &TokenPair{MarketId: slow}})
+	d.push(&Envelope{Data: &TokenPair{MarketId: fast}})
+
+	select {
+	case <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("A slow market blocked events for another market")
+	}
+
+	close(release)
+	d.close()
+}
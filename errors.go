@@ -1,27 +1,80 @@
 package vyperclientgo
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/gorilla/websocket"
+)
+
+// Sentinel errors for misuse of the clients and unexpected API responses.
+// They may be wrapped with more detail, so compare them with errors.Is.
+var (
+	ErrNotConnected      = errors.New("not connected")
+	ErrAlreadyConnected  = errors.New("already connected")
+	ErrFeedMismatch      = errors.New("feed type mismatch")
+	ErrUnexpectedPayload = errors.New("unexpected data type for API response")
+	ErrNonSuccessStatus  = errors.New("API returned non-success status")
+	ErrClosed            = errors.New("closed")
+)
+
+// maxErrorBody bounds how much of an error response's body is kept.
+const maxErrorBody = 4096
+
+// maxHandshakeBody bounds how much of a rejected handshake's body is kept.
+const maxHandshakeBody = 1024
+
+// closeBadGateway is not defined by gorilla/websocket.
+const closeBadGateway = 1014
+
+// VyperApiError is returned when the REST API answers with an error status.
+// Params has secrets redacted, RequestID is the server's X-Request-Id header
+// and Body is the raw response body, truncated to maxErrorBody bytes.
 type VyperApiError struct {
 	Message    string
 	StatusCode int
 	Response   interface{}
+	Method     string
+	Endpoint   string
+	Params     map[string]string
+	RequestID  string
+	Attempt    int
+	Elapsed    time.Duration
+	Body       string
 }
 
 func (e *VyperApiError) Error() string {
-	return fmt.Sprintf("VyperApiError: %s (Status Code: %d)", e.Message, e.StatusCode)
+	if e.Endpoint == "" {
+		return fmt.Sprintf("VyperApiError: %s (Status Code: %d)", e.Message, e.StatusCode)
+	}
+	return fmt.Sprintf("VyperApiError: %s (Status Code: %d, %s %s)", e.Message, e.StatusCode, e.Method, e.Endpoint)
 }
 
+// VyperWebsocketError is returned for failed connections and for connections
+// the server closed. StatusCode is the HTTP status of a rejected handshake and
+// Body its response body; CloseCode is the code of the server's close frame.
 type VyperWebsocketError struct {
 	Message        string
 	StatusCode     int
+	CloseCode      int
+	Body           string
 	ConnectionInfo interface{}
+	Err            error
 }
 
 func (e *VyperWebsocketError) Error() string {
 	return fmt.Sprintf("VyperWebsocketError: %s (Status Code: %d)", e.Message, e.StatusCode)
 }
 
+func (e *VyperWebsocketError) Unwrap() error {
+	return e.Err
+}
+
 type AuthenticationError struct {
 	VyperApiError
 }
@@ -34,3 +87,125 @@ type RateLimitError struct {
 type ServerError struct {
 	VyperApiError
 }
+
+// WebsocketAuthenticationError means the server rejected the API key.
+// Reconnecting with the same key will fail again.
+type WebsocketAuthenticationError struct {
+	VyperWebsocketError
+}
+
+func (e *WebsocketAuthenticationError) Unwrap() error {
+	return &e.VyperWebsocketError
+}
+
+// WebsocketRateLimitError means the server refused the connection because of
+// too many requests or connections. RetryAfter is in seconds, or 0 if the
+// server did not say.
+type WebsocketRateLimitError struct {
+	VyperWebsocketError
+	RetryAfter float64
+}
+
+func (e *WebsocketRateLimitError) Unwrap() error {
+	return &e.VyperWebsocketError
+}
+
+// WebsocketServerError means the server failed, restarted or went away.
+// Reconnecting later is expected to succeed.
+type WebsocketServerError struct {
+	VyperWebsocketError
+}
+
+func (e *WebsocketServerError) Unwrap() error {
+	return &e.VyperWebsocketError
+}
+
+// handshakeError maps a failed dial to a typed error. resp is the server's
+// reply when it rejected the upgrade, and nil when no reply was received.
+// The API key is redacted from the message and body.
+func handshakeError(err error, resp *http.Response, apiKey string, connectionInfo string) error {
+	base := VyperWebsocketError{
+		Message:        redactSecret(fmt.Sprintf("Failed to connect: %v", err), apiKey),
+		ConnectionInfo: connectionInfo,
+	}
+	if resp == nil {
+		return &base
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHandshakeBody))
+	base.StatusCode = resp.StatusCode
+	base.Body = redactSecret(strings.TrimSpace(string(body)), apiKey)
+	base.Err = websocket.ErrBadHandshake
+
+	var apiResp APIResponse
+	if json.Unmarshal(body, &apiResp) == nil && apiResp.Message != "" {
+		base.Message = redactSecret(fmt.Sprintf("Handshake rejected: %s", apiResp.Message), apiKey)
+	} else {
+		base.Message = fmt.Sprintf("Handshake rejected: %s", resp.Status)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &WebsocketAuthenticationError{base}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return &WebsocketRateLimitError{VyperWebsocketError: base, RetryAfter: retryAfter.Seconds()}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &WebsocketServerError{base}
+	}
+	return &base
+}
+
+// closeError maps a close frame received from the server to a typed error.
+// Close codes without a typed error, such as a normal closure, and other
+// errors are returned unchanged, so websocket.IsCloseError keeps working.
+func closeError(err error, feedType FeedType) error {
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		return err
+	}
+
+	base := VyperWebsocketError{
+		Message:        fmt.Sprintf("Connection closed with code %d", closeErr.Code),
+		CloseCode:      closeErr.Code,
+		ConnectionInfo: feedType,
+		Err:            err,
+	}
+	if closeErr.Text != "" {
+		base.Message += ": " + closeErr.Text
+	}
+
+	switch closeErr.Code {
+	case 4001, 4003, 4401, 4403:
+		return &WebsocketAuthenticationError{base}
+	case websocket.CloseTryAgainLater, 4029, 4429:
+		return &WebsocketRateLimitError{VyperWebsocketError: base}
+	case websocket.CloseGoingAway, websocket.CloseInternalServerErr, websocket.CloseServiceRestart, closeBadGateway:
+		return &WebsocketServerError{base}
+	}
+	return err
+}
+
+// isRetryable reports whether reconnecting after err can succeed. A rejected
+// API key or a handshake refused with another 4xx status, such as a wrong URL,
+// fails again; timeouts and rate limits do not.
+func isRetryable(err error) bool {
+	var authErr *WebsocketAuthenticationError
+	if errors.As(err, &authErr) {
+		return false
+	}
+
+	var wsErr *VyperWebsocketError
+	if errors.As(err, &wsErr) && wsErr.StatusCode >= 400 && wsErr.StatusCode < 500 {
+		return wsErr.StatusCode == http.StatusRequestTimeout || wsErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// truncateBody returns body as a string of at most maxErrorBody bytes.
+func truncateBody(body []byte) string {
+	if len(body) <= maxErrorBody {
+		return string(body)
+	}
+	return string(body[:maxErrorBody]) + "... (truncated)"
+}
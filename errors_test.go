@@ -0,0 +1,371 @@
+package vyperclientgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func isCloseCode(err error, code int) bool {
+	var closeErr *websocket.CloseError
+	return errors.As(err, &closeErr) && closeErr.Code == code
+}
+
+func TestVyperWebsocketClient_HandshakeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		body   string
+		check  func(t *testing.T, err error)
+	}{
+		{
+			name:   "unauthorized",
+			status: http.StatusUnauthorized,
+			body:   `{"status":"error","message":"invalid api key"}`,
+			check: func(t *testing.T, err error) {
+				var authErr *WebsocketAuthenticationError
+				if !errors.As(err, &authErr) {
+					t.Fatalf("Expected an authentication error, got %T: %v", err, err)
+				}
+				if !strings.Contains(authErr.Message, "invalid api key") {
+					t.Errorf("Expected the server message, got %q", authErr.Message)
+				}
+			},
+		},
+		{
+			name:   "too many connections",
+			status: http.StatusTooManyRequests,
+			header: http.Header{"Retry-After": []string{"3"}},
+			body:   "too many connections",
+			check: func(t *testing.T, err error) {
+				var rateLimitErr *WebsocketRateLimitError
+				if !errors.As(err, &rateLimitErr) {
+					t.Fatalf("Expected a rate limit error, got %T: %v", err, err)
+				}
+				if rateLimitErr.RetryAfter != 3 {
+					t.Errorf("Expected RetryAfter 3, got %v", rateLimitErr.RetryAfter)
+				}
+			},
+		},
+		{
+			name:   "unavailable",
+			status: http.StatusServiceUnavailable,
+			body:   "maintenance",
+			check: func(t *testing.T, err error) {
+				var serverErr *WebsocketServerError
+				if !errors.As(err, &serverErr) {
+					t.Fatalf("Expected a server error, got %T: %v", err, err)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range tc.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			}))
+			defer s.Close()
+
+			client := NewVyperWebsocketClient("test-api-key")
+			client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+			err := client.Connect(TokenEvents)
+			tc.check(t, err)
+
+			var wsErr *VyperWebsocketError
+			if !errors.As(err, &wsErr) {
+				t.Fatalf("Expected a VyperWebsocketError, got %T", err)
+			}
+			if wsErr.StatusCode != tc.status || wsErr.Body != tc.body {
+				t.Errorf("Expected status %d and body %q, got %d and %q", tc.status, tc.body, wsErr.StatusCode, wsErr.Body)
+			}
+			if !errors.Is(err, websocket.ErrBadHandshake) {
+				t.Error("Expected the error to wrap websocket.ErrBadHandshake")
+			}
+			if strings.Contains(err.Error(), "test-api-key") {
+				t.Errorf("API key leaked into error: %v", err)
+			}
+		})
+	}
+}
+
+func TestVyperWebsocketClient_CloseCodeErrors(t *testing.T) {
+	tests := []struct {
+		code  int
+		check func(error) bool
+	}{
+		{4001, func(err error) bool {
+			var target *WebsocketAuthenticationError
+			return errors.As(err, &target)
+		}},
+		{websocket.CloseTryAgainLater, func(err error) bool {
+			var target *WebsocketRateLimitError
+			return errors.As(err, &target)
+		}},
+		{websocket.CloseGoingAway, func(err error) bool {
+			var target *WebsocketServerError
+			return errors.As(err, &target)
+		}},
+		{websocket.CloseNormalClosure, func(err error) bool {
+			// Unmapped codes keep the gorilla error as it is.
+			return websocket.IsCloseError(err, websocket.CloseNormalClosure)
+		}},
+	}
+
+	for _, tc := range tests {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+
+			c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(tc.code, "bye"))
+		}))
+
+		client := NewVyperWebsocketClient("test-api-key")
+		client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+		if err := client.Connect(TokenEvents); err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+
+		err := client.Listen()
+		if !tc.check(err) {
+			t.Errorf("Close code %d: unexpected error %T: %v", tc.code, err, err)
+		}
+		if !isCloseCode(err, tc.code) || !strings.Contains(err.Error(), "bye") {
+			t.Errorf("Close code %d: expected the code and reason in %v", tc.code, err)
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Errorf("Close code %d: expected the gorilla close error to be wrapped", tc.code)
+		}
+
+		client.Disconnect()
+		s.Close()
+	}
+}
+
+func TestVyperMultiFeedClient_StopsOnAuthenticationClose(t *testing.T) {
+	var connections int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		atomic.AddInt32(&connections, 1)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4001, "api key revoked"))
+	}))
+	defer s.Close()
+
+	errs := make(chan error, 10)
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ReconnectDelay = 10 * time.Millisecond
+	client.ErrorHandler = func(feedType FeedType, err error) {
+		errs <- err
+	}
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	select {
+	case err := <-errs:
+		var authErr *WebsocketAuthenticationError
+		if !errors.As(err, &authErr) {
+			t.Errorf("Expected an authentication error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the close error")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&connections); got != 1 {
+		t.Errorf("Expected no reconnect after an authentication failure, got %d connections", got)
+	}
+	if got := client.States()[TokenEvents]; got != StateDisconnected {
+		t.Errorf("Expected the feed to stay disconnected, got %s", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset"), true},
+		{&WebsocketAuthenticationError{VyperWebsocketError{StatusCode: 401}}, false},
+		{&VyperWebsocketError{StatusCode: http.StatusNotFound}, false},
+		{&VyperWebsocketError{StatusCode: http.StatusBadRequest}, false},
+		{&VyperWebsocketError{StatusCode: http.StatusRequestTimeout}, true},
+		{&WebsocketRateLimitError{VyperWebsocketError: VyperWebsocketError{StatusCode: http.StatusTooManyRequests}}, true},
+		{&WebsocketServerError{VyperWebsocketError{StatusCode: http.StatusBadGateway}}, true},
+		{&WebsocketServerError{VyperWebsocketError{CloseCode: websocket.CloseGoingAway}}, true},
+	}
+	for _, tc := range tests {
+		if got := isRetryable(tc.err); got != tc.want {
+			t.Errorf("isRetryable(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestVyperMultiFeedClient_StopsOnNotFound(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			http.NotFound(w, r)
+			return
+		}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "moving"))
+	}))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ReconnectDelay = 10 * time.Millisecond
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.States()[TokenEvents] != StateDisconnected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the feed to give up, still %s", client.States()[TokenEvents])
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("Expected no redial after a 404, got %d requests", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	rateLimited := &WebsocketRateLimitError{RetryAfter: 2}
+	if got := retryDelay(rateLimited, time.Second); got != 2*time.Second {
+		t.Errorf("Expected the server's Retry-After, got %v", got)
+	}
+	if got := retryDelay(rateLimited, 5*time.Second); got != 5*time.Second {
+		t.Errorf("Expected the longer backoff to be kept, got %v", got)
+	}
+	if got := retryDelay(errors.New("dropped"), time.Second); got != time.Second {
+		t.Errorf("Expected the backoff for other errors, got %v", got)
+	}
+}
+
+func TestSentinelErrors_Websocket(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	notConnected := map[string]error{
+		"Subscribe":        client.Subscribe(TokenEvents, TokenSubscriptionMessage{}),
+		"SubscribeWallets": client.SubscribeWallets("wallet-1"),
+		"Listen":           client.Listen(),
+		"Ping":             client.Ping(),
+		"Disconnect":       client.Disconnect(),
+		"Close":            client.Close(context.Background()),
+	}
+	for name, err := range notConnected {
+		if !errors.Is(err, ErrNotConnected) {
+			t.Errorf("%s: expected ErrNotConnected, got %v", name, err)
+		}
+	}
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	if err := client.Connect(TokenEvents); !errors.Is(err, ErrAlreadyConnected) {
+		t.Errorf("Expected ErrAlreadyConnected, got %v", err)
+	}
+	if err := client.Subscribe(WalletEvents, WalletSubscriptionMessage{}); !errors.Is(err, ErrFeedMismatch) {
+		t.Errorf("Expected ErrFeedMismatch, got %v", err)
+	}
+	err := client.SubscribeWallets("wallet-1")
+	if !errors.Is(err, ErrFeedMismatch) || !strings.Contains(err.Error(), string(TokenEvents)) {
+		t.Errorf("Expected a wrapped ErrFeedMismatch naming the feed, got %v", err)
+	}
+
+	multi := NewVyperMultiFeedClient("test-api-key")
+	if err := multi.Subscribe(WalletEvents, WalletSubscriptionMessage{}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected from the multi-feed client, got %v", err)
+	}
+}
+
+func TestSentinelErrors_Rest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/chain/ids":
+			w.Write([]byte(`{"status":"error","message":"maintenance","data":{}}`))
+		default:
+			w.Write([]byte(`{"status":"success","message":"ok","data":42}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewVyperClient("test-api-key")
+	client.BaseURL = server.URL
+
+	_, err := client.GetChainIds()
+	if !errors.Is(err, ErrNonSuccessStatus) || !strings.Contains(err.Error(), "error") {
+		t.Errorf("Expected a wrapped ErrNonSuccessStatus, got %v", err)
+	}
+
+	_, err = client.GetTokenMetadata(900, "market-1")
+	if !errors.Is(err, ErrUnexpectedPayload) {
+		t.Errorf("Expected ErrUnexpectedPayload, got %v", err)
+	}
+}
+
+func TestSentinelErrors_Closed(t *testing.T) {
+	multi := NewVyperMultiFeedClient("test-api-key")
+	multi.Close()
+	if err := multi.Connect(TokenEvents); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from the multi-feed client, got %v", err)
+	}
+
+	tracker := NewVyperWalletTracker("test-api-key")
+	tracker.Close()
+	if err := tracker.AddWallets("wallet-1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from AddWallets, got %v", err)
+	}
+	if err := tracker.RemoveWallets("wallet-1"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from RemoveWallets, got %v", err)
+	}
+
+	replayer := NewFeedReplayer(strings.NewReader(`{"feedType":"token-events","data":{"marketId":"market-1"}}`))
+	replayer.Speed = 0
+	replayer.Close()
+	if err := replayer.Listen(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from the replayer, got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+package vyperclientgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPingInterval      = 30 * time.Second
+	defaultReconnectDelay    = time.Second
+	defaultMaxReconnectDelay = 30 * time.Second
+)
+
+// feedConn keeps a single VyperWebsocketClient connected to one feed. It runs
+// the listen loop, pings the server periodically and, when the connection
+// drops, reconnects with exponential backoff and restores the token types and
+// wallets that were subscribed on the previous connection. A connection that
+// answers no ping for two ping intervals is treated as dropped.
+type feedConn struct {
+	client            *VyperWebsocketClient
+	feedType          FeedType
+	pingInterval      time.Duration
+	reconnectDelay    time.Duration
+	maxReconnectDelay time.Duration
+	onError           func(error)
+
+	mu        sync.Mutex
+	types     []SubscriptionType
+	wallets   []string
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newFeedConn(client *VyperWebsocketClient, feedType FeedType) *feedConn {
+	client.managed = true
+	return &feedConn{
+		client:            client,
+		feedType:          feedType,
+		pingInterval:      defaultPingInterval,
+		reconnectDelay:    defaultReconnectDelay,
+		maxReconnectDelay: defaultMaxReconnectDelay,
+		done:              make(chan struct{}),
+	}
+}
+
+func (f *feedConn) start(handler EnvelopeHandler) error {
+	f.client.SetEnvelopeHandler(handler)
+
+	f.mu.Lock()
+	err := f.client.Connect(f.feedType)
+	if err == nil {
+		err = f.watchPongs()
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f.wg.Add(1)
+	go f.run()
+	if f.pingInterval > 0 {
+		f.wg.Add(1)
+		go f.keepalive()
+	}
+	return nil
+}
+
+// subscribe sends message on the feed. While the feed is reconnecting, token
+// and wallet subscription messages are applied to the sets restored by redial
+// instead.
+func (f *feedConn) subscribe(message interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.client.isConnected() || f.client.State() != StateReconnecting {
+		return f.client.Subscribe(f.feedType, message)
+	}
+
+	switch m := message.(type) {
+	case TokenSubscriptionMessage:
+		return f.queueTokenTypes(m.Action, m.Types)
+	case *TokenSubscriptionMessage:
+		return f.queueTokenTypes(m.Action, m.Types)
+	case WalletSubscriptionMessage:
+		return f.queueWallets(m.Action, m.Wallets)
+	case *WalletSubscriptionMessage:
+		return f.queueWallets(m.Action, m.Wallets)
+	}
+	return ErrNotConnected
+}
+
+// updateWallets changes the wallets subscribed on the feed. While the feed is
+// reconnecting the change is applied to the set restored by redial instead.
+func (f *feedConn) updateWallets(action SubscriptionMessageType, wallets []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.client.isConnected() {
+		return f.client.updateWallets(action, wallets)
+	}
+	return f.queueWallets(action, wallets)
+}
+
+// queueTokenTypes applies a change to the token types restored by redial.
+// The caller must hold f.mu.
+func (f *feedConn) queueTokenTypes(action SubscriptionMessageType, types []SubscriptionType) error {
+	if f.feedType != TokenEvents && f.feedType != MigrationEvents {
+		return fmt.Errorf("%w: token types cannot be subscribed on %s", ErrFeedMismatch, f.feedType)
+	}
+
+	remaining := make(map[SubscriptionType]struct{}, len(f.types)+len(types))
+	for _, subscriptionType := range f.types {
+		remaining[subscriptionType] = struct{}{}
+	}
+	for _, subscriptionType := range types {
+		if action == Subscribe {
+			remaining[subscriptionType] = struct{}{}
+		} else {
+			delete(remaining, subscriptionType)
+		}
+	}
+
+	f.types = f.types[:0]
+	for subscriptionType := range remaining {
+		f.types = append(f.types, subscriptionType)
+	}
+	return nil
+}
+
+// queueWallets applies a change to the wallets restored by redial. The
+// caller must hold f.mu.
+func (f *feedConn) queueWallets(action SubscriptionMessageType, wallets []string) error {
+	if f.feedType != WalletEvents {
+		return fmt.Errorf("%w: wallets cannot be subscribed on %s", ErrFeedMismatch, f.feedType)
+	}
+
+	remaining := make(map[string]struct{}, len(f.wallets)+len(wallets))
+	for _, wallet := range f.wallets {
+		remaining[wallet] = struct{}{}
+	}
+	for _, wallet := range wallets {
+		if action == Subscribe {
+			remaining[wallet] = struct{}{}
+		} else {
+			delete(remaining, wallet)
+		}
+	}
+
+	f.wallets = f.wallets[:0]
+	for wallet := range remaining {
+		f.wallets = append(f.wallets, wallet)
+	}
+	return nil
+}
+
+func (f *feedConn) run() {
+	defer f.wg.Done()
+
+	for {
+		err := f.client.Listen()
+		if f.isClosed() {
+			return
+		}
+		f.reportError(err)
+
+		f.mu.Lock()
+		f.types = f.client.SubscribedTokenTypes()
+		f.wallets = f.client.SubscribedWallets()
+		if !isRetryable(err) && !f.client.credentialsRotated() {
+			f.client.giveUp(err)
+			f.mu.Unlock()
+			return
+		}
+		f.client.beginReconnect(err)
+		f.mu.Unlock()
+
+		if !f.reconnect(retryDelay(err, f.reconnectDelay)) {
+			return
+		}
+	}
+}
+
+// reconnect redials with exponential backoff, starting at delay. It gives up
+// when the feed is closed or the server rejects the API key, unless the
+// credentials provider has rotated it since.
+func (f *feedConn) reconnect(delay time.Duration) bool {
+	for {
+		select {
+		case <-f.done:
+			return false
+		case <-time.After(delay):
+		}
+
+		err := f.redial()
+		if err == nil {
+			return true
+		}
+		f.reportError(err)
+		if !isRetryable(err) && !f.client.credentialsRotated() {
+			f.mu.Lock()
+			f.client.giveUp(err)
+			f.mu.Unlock()
+			return false
+		}
+
+		delay *= 2
+		if delay > f.maxReconnectDelay {
+			delay = f.maxReconnectDelay
+		}
+		delay = retryDelay(err, delay)
+	}
+}
+
+// retryDelay stretches delay to the wait requested by a rate-limited server.
+func retryDelay(err error, delay time.Duration) time.Duration {
+	var rateLimitErr *WebsocketRateLimitError
+	if errors.As(err, &rateLimitErr) {
+		if wait := time.Duration(rateLimitErr.RetryAfter * float64(time.Second)); wait > delay {
+			return wait
+		}
+	}
+	return delay
+}
+
+func (f *feedConn) redial() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.isClosed() {
+		return nil
+	}
+
+	if err := f.client.Connect(f.feedType); err != nil {
+		return err
+	}
+
+	err := f.watchPongs()
+	if err == nil && len(f.types) > 0 {
+		err = f.client.SubscribeTokenTypes(f.types...)
+	}
+	if err == nil && len(f.wallets) > 0 {
+		err = f.client.SubscribeWallets(f.wallets...)
+	}
+	if err != nil {
+		f.client.beginReconnect(err)
+		return err
+	}
+	return nil
+}
+
+func (f *feedConn) keepalive() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			// A failed ping closes the connection, so Listen in run returns
+			// and the feed reconnects.
+			f.client.keepalivePing(f.pingInterval)
+		}
+	}
+}
+
+// watchPongs makes Listen fail when a read sees no frame or pong for two ping
+// intervals. Reads held up by a slow consumer are not timed out. It must be
+// called before Listen runs on the new connection.
+func (f *feedConn) watchPongs() error {
+	if f.pingInterval <= 0 {
+		return nil
+	}
+	return f.client.setPongTimeout(2 * f.pingInterval)
+}
+
+func (f *feedConn) reportError(err error) {
+	if err != nil && f.onError != nil {
+		f.onError(err)
+	}
+}
+
+func (f *feedConn) isClosed() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *feedConn) close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		close(f.done)
+
+		f.mu.Lock()
+		if f.client.Conn != nil {
+			err = f.client.Disconnect()
+		} else {
+			f.client.abandonReconnect()
+		}
+		f.mu.Unlock()
+
+		f.wg.Wait()
+	})
+	return err
+}
@@ -0,0 +1,176 @@
+package vyperclientgo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type FeedEvent struct {
+	FeedType     FeedType
+	ConnectionID string
+	Sequence     uint64
+	ReceivedAt   time.Time
+	Data         interface{}
+}
+
+type FeedErrorHandler func(feedType FeedType, err error)
+
+// VyperMultiFeedClient owns one connection per feed type and merges the
+// events of all of them into a single channel. Dropped connections are
+// re-established and resubscribed automatically until Close is called.
+type VyperMultiFeedClient struct {
+	BaseURL           string
+	ApiKey            string
+	Credentials       CredentialsProvider
+	PingInterval      time.Duration
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+	ErrorHandler      FeedErrorHandler
+	Deduplicator      *Deduplicator
+	ConfigureClient   func(client *VyperWebsocketClient)
+
+	events chan FeedEvent
+	feeds  map[FeedType]*feedConn
+	closed bool
+	mu     sync.Mutex
+}
+
+func NewVyperMultiFeedClient(apiKey string) *VyperMultiFeedClient {
+	return &VyperMultiFeedClient{
+		BaseURL:           defaultWebsocketURL,
+		ApiKey:            apiKey,
+		PingInterval:      defaultPingInterval,
+		ReconnectDelay:    defaultReconnectDelay,
+		MaxReconnectDelay: defaultMaxReconnectDelay,
+		events:            make(chan FeedEvent, 256),
+		feeds:             make(map[FeedType]*feedConn),
+	}
+}
+
+func (m *VyperMultiFeedClient) Connect(feedTypes ...FeedType) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return fmt.Errorf("client %w", ErrClosed)
+	}
+
+	var opened []*feedConn
+	for _, feedType := range feedTypes {
+		if _, ok := m.feeds[feedType]; ok {
+			continue
+		}
+
+		feed := m.newFeed(feedType)
+		if err := feed.start(m.handlerFor(feed)); err != nil {
+			for _, f := range opened {
+				f.close()
+				delete(m.feeds, f.feedType)
+			}
+			return err
+		}
+
+		m.feeds[feedType] = feed
+		opened = append(opened, feed)
+	}
+	return nil
+}
+
+func (m *VyperMultiFeedClient) newFeed(feedType FeedType) *feedConn {
+	client := NewVyperWebsocketClient(m.ApiKey)
+	client.BaseURL = m.BaseURL
+	client.Credentials = m.Credentials
+	client.Deduplicator = m.Deduplicator
+	if m.ConfigureClient != nil {
+		m.ConfigureClient(client)
+	}
+
+	feed := newFeedConn(client, feedType)
+	feed.pingInterval = m.PingInterval
+	feed.reconnectDelay = m.ReconnectDelay
+	feed.maxReconnectDelay = m.MaxReconnectDelay
+	if m.ErrorHandler != nil {
+		handler := m.ErrorHandler
+		feed.onError = func(err error) {
+			handler(feedType, err)
+		}
+	}
+	return feed
+}
+
+func (m *VyperMultiFeedClient) handlerFor(feed *feedConn) EnvelopeHandler {
+	return func(env *Envelope) {
+		event := FeedEvent{
+			FeedType:     env.FeedType,
+			ConnectionID: env.ConnectionID,
+			Sequence:     env.Sequence,
+			ReceivedAt:   env.ReceivedAt,
+			Data:         env.Data,
+		}
+		select {
+		case m.events <- event:
+		case <-feed.done:
+		}
+	}
+}
+
+func (m *VyperMultiFeedClient) feed(feedType FeedType) (*feedConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, ok := m.feeds[feedType]
+	if !ok {
+		return nil, fmt.Errorf("%w to %s", ErrNotConnected, feedType)
+	}
+	return feed, nil
+}
+
+// States reports the connection state of every connected feed.
+func (m *VyperMultiFeedClient) States() map[FeedType]ConnectionState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	states := make(map[FeedType]ConnectionState, len(m.feeds))
+	for feedType, feed := range m.feeds {
+		states[feedType] = feed.client.State()
+	}
+	return states
+}
+
+func (m *VyperMultiFeedClient) Subscribe(feedType FeedType, message interface{}) error {
+	feed, err := m.feed(feedType)
+	if err != nil {
+		return err
+	}
+	return feed.subscribe(message)
+}
+
+func (m *VyperMultiFeedClient) Unsubscribe(feedType FeedType, message interface{}) error {
+	return m.Subscribe(feedType, message)
+}
+
+func (m *VyperMultiFeedClient) Events() <-chan FeedEvent {
+	return m.events
+}
+
+func (m *VyperMultiFeedClient) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	var firstErr error
+	for feedType, feed := range m.feeds {
+		if err := feed.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(m.feeds, feedType)
+	}
+
+	close(m.events)
+	return firstErr
+}
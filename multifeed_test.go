@@ -0,0 +1,330 @@
+package vyperclientgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestVyperMultiFeedClient_MergesFeeds(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		var payload interface{}
+		switch {
+		case strings.HasSuffix(r.URL.Path, string(WalletEvents)):
+			payload = ChainAction{Signer: "wallet-1", TransactionId: "tx-1"}
+		default:
+			payload = TokenPair{MarketId: "market-1"}
+		}
+
+		data, _ := json.Marshal(payload)
+		if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(TokenEvents, WalletEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	received := make(map[FeedType]interface{})
+	for len(received) < 2 {
+		select {
+		case event := <-client.Events():
+			received[event.FeedType] = event.Data
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for events, got %v", received)
+		}
+	}
+
+	if pair, ok := received[TokenEvents].(*TokenPair); !ok || pair.MarketId != "market-1" {
+		t.Errorf("Unexpected token event: %+v", received[TokenEvents])
+	}
+	if action, ok := received[WalletEvents].(*ChainAction); !ok || action.TransactionId != "tx-1" {
+		t.Errorf("Unexpected wallet event: %+v", received[WalletEvents])
+	}
+}
+
+func TestVyperMultiFeedClient_ReconnectResubscribes(t *testing.T) {
+	var connections int32
+	resubscribed := make(chan []byte, 1)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		n := atomic.AddInt32(&connections, 1)
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		if n == 1 {
+			// Drop the first connection right after the subscription.
+			return
+		}
+		resubscribed <- message
+
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ReconnectDelay = 10 * time.Millisecond
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	subscriptionMessage := TokenSubscriptionMessage{
+		Action: Subscribe,
+		Types:  []SubscriptionType{PumpfunTokens},
+	}
+	err = client.Subscribe(TokenEvents, subscriptionMessage)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	select {
+	case message := <-resubscribed:
+		var got TokenSubscriptionMessage
+		if err := json.Unmarshal(message, &got); err != nil {
+			t.Fatalf("Failed to decode resubscription: %v", err)
+		}
+		if got.Action != Subscribe || len(got.Types) != 1 || got.Types[0] != PumpfunTokens {
+			t.Errorf("Unexpected resubscription message: %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for resubscription")
+	}
+}
+
+func TestVyperMultiFeedClient_CloseClosesEvents(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(TokenEvents, MigrationEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	err = client.Close()
+	if err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	select {
+	case _, ok := <-client.Events():
+		if ok {
+			t.Fatal("Expected events channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Events channel was not closed")
+	}
+
+	if err := client.Connect(TokenEvents); err == nil {
+		t.Fatal("Expected Connect after Close to fail")
+	}
+}
+
+func TestVyperMultiFeedClient_ReconnectsWithoutPongs(t *testing.T) {
+	var connections int32
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// Never read, so the client's pings are never answered.
+			<-release
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+	defer close(release)
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.PingInterval = 50 * time.Millisecond
+	client.ReconnectDelay = 10 * time.Millisecond
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&connections) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a reconnect after missing pongs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The second server answers pings, so the connection is kept.
+	time.Sleep(300 * time.Millisecond)
+	if got := atomic.LoadInt32(&connections); got != 2 {
+		t.Errorf("Expected the answered connection to be kept, got %d connections", got)
+	}
+}
+
+func TestVyperMultiFeedClient_SubscribeWhileReconnecting(t *testing.T) {
+	var connections int32
+	resubscribed := make(chan []byte, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// Drop the first connection straight away.
+			return
+		}
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		resubscribed <- message
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ReconnectDelay = 200 * time.Millisecond
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.States()[TokenEvents] != StateReconnecting {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the feed to reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	message := TokenSubscriptionMessage{Action: Subscribe, Types: []SubscriptionType{PumpfunTokens}}
+	if err := client.Subscribe(TokenEvents, message); err != nil {
+		t.Fatalf("Expected the subscription to be queued, got %v", err)
+	}
+	if err := client.Subscribe(TokenEvents, WalletSubscriptionMessage{Action: Subscribe, Wallets: []string{"wallet-1"}}); !errors.Is(err, ErrFeedMismatch) {
+		t.Errorf("Expected ErrFeedMismatch for wallets on token-events, got %v", err)
+	}
+
+	select {
+	case data := <-resubscribed:
+		var got TokenSubscriptionMessage
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Failed to decode resubscription: %v", err)
+		}
+		if got.Action != Subscribe || len(got.Types) != 1 || got.Types[0] != PumpfunTokens {
+			t.Errorf("Unexpected resubscription message: %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the queued subscription")
+	}
+}
+
+func TestVyperMultiFeedClient_SlowConsumerKeepsConnection(t *testing.T) {
+	const count = 300
+	var connections int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		atomic.AddInt32(&connections, 1)
+		for i := 0; i < count; i++ {
+			data, _ := json.Marshal(TokenPair{MarketId: fmt.Sprintf("market-%d", i)})
+			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.PingInterval = 20 * time.Millisecond
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Leave the events unread long enough to block Listen past the pong
+	// timeout.
+	time.Sleep(200 * time.Millisecond)
+
+	for i := 0; i < count; i++ {
+		select {
+		case <-client.Events():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out after %d events", i)
+		}
+	}
+	if got := atomic.LoadInt32(&connections); got != 1 {
+		t.Errorf("Expected the blocked connection to be kept, got %d connections", got)
+	}
+}
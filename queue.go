@@ -0,0 +1,115 @@
+package vyperclientgo
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what Listen does with a message when the queue in
+// front of the handlers is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading from the socket until the queue has room.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued message to make room.
+	OverflowDropOldest
+	// OverflowDropNewest discards the message that did not fit.
+	OverflowDropNewest
+	// OverflowDisconnect closes the connection and makes Listen return.
+	OverflowDisconnect
+)
+
+type ListenerStats struct {
+	Queued      uint64
+	Dropped     uint64
+	Skipped     uint64
+	Duplicates  uint64
+	QueueLength int
+}
+
+type listenerCounters struct {
+	queued     atomic.Uint64
+	dropped    atomic.Uint64
+	skipped    atomic.Uint64
+	duplicates atomic.Uint64
+	length     atomic.Int64
+}
+
+type eventQueue struct {
+	items    chan *Envelope
+	policy   OverflowPolicy
+	deliver  func(*Envelope)
+	counters *listenerCounters
+	wg       sync.WaitGroup
+}
+
+func newEventQueue(size int, policy OverflowPolicy, counters *listenerCounters, deliver func(*Envelope)) *eventQueue {
+	q := &eventQueue{
+		items:    make(chan *Envelope, size),
+		policy:   policy,
+		deliver:  deliver,
+		counters: counters,
+	}
+
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+func (q *eventQueue) run() {
+	defer q.wg.Done()
+
+	for env := range q.items {
+		q.counters.length.Add(-1)
+		q.deliver(env)
+	}
+}
+
+// push queues env according to the overflow policy. It reports false only
+// when the policy is OverflowDisconnect and the queue is full.
+func (q *eventQueue) push(env *Envelope) bool {
+	// The length is raised before the send so the consumer never sees it
+	// drop below zero.
+	q.counters.length.Add(1)
+
+	if q.policy == OverflowBlock {
+		q.items <- env
+		q.counters.queued.Add(1)
+		return true
+	}
+
+	for {
+		select {
+		case q.items <- env:
+			q.counters.queued.Add(1)
+			return true
+		default:
+		}
+
+		switch q.policy {
+		case OverflowDropNewest:
+			q.counters.length.Add(-1)
+			q.counters.dropped.Add(1)
+			return true
+		case OverflowDisconnect:
+			q.counters.length.Add(-1)
+			q.counters.dropped.Add(1)
+			return false
+		}
+
+		select {
+		case <-q.items:
+			q.counters.length.Add(-1)
+			q.counters.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// close stops accepting messages and waits for the queued ones to be
+// delivered.
+func (q *eventQueue) close() {
+	close(q.items)
+	q.wg.Wait()
+}
@@ -0,0 +1,159 @@
+package vyperclientgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// blockedQueue returns a queue of the given size whose consumer is stuck
+// delivering the first item until release is closed.
+func blockedQueue(t *testing.T, size int, policy OverflowPolicy) (*eventQueue, *listenerCounters, chan struct{}, *[]uint64) {
+	t.Helper()
+
+	counters := &listenerCounters{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var delivered []uint64
+
+	q := newEventQueue(size, policy, counters, func(env *Envelope) {
+		if len(delivered) == 0 {
+			close(started)
+			<-release
+		}
+		delivered = append(delivered, env.Sequence)
+	})
+
+	q.push(&Envelope{Sequence: 0})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the consumer to start")
+	}
+	return q, counters, release, &delivered
+}
+
+func TestEventQueue_DropNewest(t *testing.T) {
+	q, counters, release, delivered := blockedQueue(t, 2, OverflowDropNewest)
+
+	for i := uint64(1); i <= 4; i++ {
+		if !q.push(&Envelope{Sequence: i}) {
+			t.Fatalf("push %d unexpectedly reported overflow", i)
+		}
+	}
+	close(release)
+	q.close()
+
+	if !reflect.DeepEqual(*delivered, []uint64{0, 1, 2}) {
+		t.Errorf("Unexpected delivered items: %v", *delivered)
+	}
+	if got := counters.dropped.Load(); got != 2 {
+		t.Errorf("Expected 2 dropped, got %d", got)
+	}
+	if got := counters.queued.Load(); got != 3 {
+		t.Errorf("Expected 3 queued, got %d", got)
+	}
+}
+
+func TestEventQueue_DropOldest(t *testing.T) {
+	q, counters, release, delivered := blockedQueue(t, 2, OverflowDropOldest)
+
+	for i := uint64(1); i <= 4; i++ {
+		q.push(&Envelope{Sequence: i})
+	}
+	close(release)
+	q.close()
+
+	if !reflect.DeepEqual(*delivered, []uint64{0, 3, 4}) {
+		t.Errorf("Unexpected delivered items: %v", *delivered)
+	}
+	if got := counters.dropped.Load(); got != 2 {
+		t.Errorf("Expected 2 dropped, got %d", got)
+	}
+	if got := counters.length.Load(); got != 0 {
+		t.Errorf("Expected empty queue, got length %d", got)
+	}
+}
+
+func TestEventQueue_Disconnect(t *testing.T) {
+	q, counters, release, _ := blockedQueue(t, 1, OverflowDisconnect)
+
+	if !q.push(&Envelope{Sequence: 1}) {
+		t.Fatal("Expected the first push to fit")
+	}
+	if q.push(&Envelope{Sequence: 2}) {
+		t.Fatal("Expected overflow to be reported")
+	}
+	close(release)
+	q.close()
+
+	if got := counters.dropped.Load(); got != 1 {
+		t.Errorf("Expected 1 dropped, got %d", got)
+	}
+}
+
+func TestVyperWebsocketClient_ListenQueueOverflowDisconnects(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		data, _ := json.Marshal(TokenPair{MarketId: "test-market"})
+		for i := 0; i < 10; i++ {
+			if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		}
+		c.ReadMessage()
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.QueueSize = 1
+	client.OverflowPolicy = OverflowDisconnect
+
+	release := make(chan struct{})
+	client.SetMessageHandler(func(data interface{}) {
+		<-release
+	})
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	errorChan := make(chan error, 1)
+	go func() {
+		errorChan <- client.Listen()
+	}()
+
+	// Listen drains the queue before returning, so unblock the handler once
+	// the overflow has been counted.
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Stats().Dropped == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for an overflow")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case err := <-errorChan:
+		wsErr, ok := err.(*VyperWebsocketError)
+		if !ok || wsErr.Message != "message queue overflow" {
+			t.Errorf("Expected queue overflow error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Listen to return")
+	}
+}
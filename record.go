@@ -0,0 +1,288 @@
+package vyperclientgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const maxRecordedFrameSize = 16 * 1024 * 1024
+
+// FeedSource streams decoded events to a message handler until the stream
+// ends. Both the live VyperWebsocketClient and FeedReplayer implement it, so
+// consumers can be tested against recorded traffic.
+type FeedSource interface {
+	SetMessageHandler(handler MessageHandler)
+	Listen() error
+}
+
+var (
+	_ FeedSource = (*VyperWebsocketClient)(nil)
+	_ FeedSource = (*FeedReplayer)(nil)
+)
+
+// recordedFrame is one line of an NDJSON recording. A frame that is not
+// valid JSON is kept as a string in Text instead of Data.
+type recordedFrame struct {
+	ReceivedAt   time.Time       `json:"receivedAt"`
+	FeedType     FeedType        `json:"feedType"`
+	ConnectionID string          `json:"connectionId,omitempty"`
+	Sequence     uint64          `json:"sequence,omitempty"`
+	Data         json.RawMessage `json:"data,omitempty"`
+	Text         string          `json:"text,omitempty"`
+}
+
+func (f *recordedFrame) raw() []byte {
+	if f.Data != nil {
+		return f.Data
+	}
+	return []byte(f.Text)
+}
+
+// FeedRecorder writes raw frames to NDJSON, one frame per line. Its Record
+// method is a RawMessageHandler and may be shared by several clients.
+type FeedRecorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	buf    *bufio.Writer
+	closer io.Closer
+	err    error
+}
+
+func NewFeedRecorder(w io.Writer) *FeedRecorder {
+	return &FeedRecorder{w: w}
+}
+
+func CreateFeedRecording(path string) (*FeedRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(f)
+	return &FeedRecorder{w: buf, buf: buf, closer: f}, nil
+}
+
+// Record appends the frame to the recording, including frames that are not
+// valid JSON. The first write error is kept and returned by Err and Close;
+// later frames are ignored.
+func (r *FeedRecorder) Record(message RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	frame := recordedFrame{
+		ReceivedAt:   message.ReceivedAt,
+		FeedType:     message.FeedType,
+		ConnectionID: message.ConnectionID,
+		Sequence:     message.Sequence,
+	}
+	if json.Valid(message.Data) {
+		frame.Data = message.Data
+	} else {
+		frame.Text = string(message.Data)
+	}
+
+	line, err := json.Marshal(frame)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		r.err = err
+	}
+}
+
+func (r *FeedRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func (r *FeedRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.buf != nil {
+		if err := r.buf.Flush(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	if r.closer != nil {
+		if err := r.closer.Close(); err != nil && r.err == nil {
+			r.err = err
+		}
+		r.closer = nil
+	}
+	return r.err
+}
+
+// FeedReplayer plays back a recording made by FeedRecorder. Speed scales the
+// original timing: 1 replays in real time, 10 ten times faster, and 0 as fast
+// as possible. Envelopes carry the recorded receive time and sequence.
+// Recorded frames that cannot be decoded are handled as by the live client,
+// according to ErrorHandler and ErrorPolicy.
+type FeedReplayer struct {
+	Speed           float64
+	MessageHandler  MessageHandler
+	EnvelopeHandler EnvelopeHandler
+	ControlHandler  ControlHandler
+	ErrorHandler    FrameErrorHandler
+	ErrorPolicy     ErrorPolicy
+
+	r         io.Reader
+	closer    io.Closer
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewFeedReplayer(r io.Reader) *FeedReplayer {
+	return &FeedReplayer{
+		Speed: 1,
+		r:     r,
+		done:  make(chan struct{}),
+	}
+}
+
+func OpenFeedReplay(path string) (*FeedReplayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewFeedReplayer(f)
+	p.closer = f
+	return p, nil
+}
+
+func (p *FeedReplayer) SetMessageHandler(handler MessageHandler) {
+	p.MessageHandler = handler
+}
+
+func (p *FeedReplayer) SetEnvelopeHandler(handler EnvelopeHandler) {
+	p.EnvelopeHandler = handler
+}
+
+func (p *FeedReplayer) SetControlHandler(handler ControlHandler) {
+	p.ControlHandler = handler
+}
+
+func (p *FeedReplayer) SetErrorHandler(handler FrameErrorHandler) {
+	p.ErrorHandler = handler
+}
+
+// Listen replays the recording and returns nil once it is exhausted, or an
+// error if a line cannot be read or decoded.
+func (p *FeedReplayer) Listen() error {
+	scanner := bufio.NewScanner(p.r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordedFrameSize)
+
+	var first time.Time
+	start := time.Now()
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var frame recordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return fmt.Errorf("recording line %d: %w", line, err)
+		}
+
+		if first.IsZero() {
+			first = frame.ReceivedAt
+		}
+		if p.Speed > 0 {
+			offset := time.Duration(float64(frame.ReceivedAt.Sub(first)) / p.Speed)
+			if err := p.wait(time.Until(start.Add(offset))); err != nil {
+				return err
+			}
+		} else if p.closed() {
+			return fmt.Errorf("replay %w", ErrClosed)
+		}
+
+		raw := frame.raw()
+		data, err := decodeMessage(frame.FeedType, raw)
+		if err != nil {
+			if p.ErrorHandler != nil {
+				p.ErrorHandler(raw, err)
+			}
+			if p.ErrorPolicy == ErrorPolicySkip {
+				continue
+			}
+			return fmt.Errorf("recording line %d: %w", line, err)
+		}
+
+		if control, ok := data.(*ControlMessage); ok {
+			if p.ControlHandler != nil {
+				p.ControlHandler(control)
+			}
+			continue
+		}
+
+		if p.EnvelopeHandler != nil {
+			p.EnvelopeHandler(&Envelope{
+				FeedType:     frame.FeedType,
+				ConnectionID: frame.ConnectionID,
+				Sequence:     frame.Sequence,
+				ReceivedAt:   frame.ReceivedAt,
+				Size:         len(raw),
+				Data:         data,
+			})
+		}
+		if p.MessageHandler != nil {
+			p.MessageHandler(data)
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *FeedReplayer) wait(d time.Duration) error {
+	if d <= 0 {
+		if p.closed() {
+			return fmt.Errorf("replay %w", ErrClosed)
+		}
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.done:
+		return fmt.Errorf("replay %w", ErrClosed)
+	}
+}
+
+func (p *FeedReplayer) closed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops a running Listen and closes the recording file.
+func (p *FeedReplayer) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.done)
+		if p.closer != nil {
+			err = p.closer.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,180 @@
+package vyperclientgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestFeedRecorder_RecordAndReplay(t *testing.T) {
+	pairs := []TokenPair{
+		{MarketId: "market-1", Name: "First", Symbol: "ONE"},
+		{MarketId: "market-2", Name: "Second", Symbol: "TWO"},
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for _, pair := range pairs {
+			data, _ := json.Marshal(pair)
+			c.WriteMessage(websocket.TextMessage, data)
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(`{"status":"subscribed"}`))
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "token-events.ndjson")
+	recorder, err := CreateFeedRecording(path)
+	if err != nil {
+		t.Fatalf("Failed to create recording: %v", err)
+	}
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.SetRawHandler(recorder.Record)
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	client.Listen()
+	client.Disconnect()
+
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recording: %v", err)
+	}
+
+	replayer, err := OpenFeedReplay(path)
+	if err != nil {
+		t.Fatalf("Failed to open recording: %v", err)
+	}
+	defer replayer.Close()
+	replayer.Speed = 0
+
+	var source FeedSource = replayer
+	var replayed []TokenPair
+	source.SetMessageHandler(func(data interface{}) {
+		replayed = append(replayed, *data.(*TokenPair))
+	})
+
+	controls := 0
+	replayer.SetControlHandler(func(*ControlMessage) {
+		controls++
+	})
+
+	if err := source.Listen(); err != nil {
+		t.Fatalf("Replay returned an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(replayed, pairs) {
+		t.Errorf("Expected replayed pairs %+v, got %+v", pairs, replayed)
+	}
+	if controls != 1 {
+		t.Errorf("Expected 1 replayed control message, got %d", controls)
+	}
+}
+
+func TestFeedReplayer_Timing(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewFeedRecorder(&buf)
+
+	base := time.Date(2024, 9, 26, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		data, _ := json.Marshal(ChainAction{Signer: "wallet-1", TransactionId: "tx"})
+		recorder.Record(RawMessage{
+			FeedType:     WalletEvents,
+			ConnectionID: "conn-1",
+			Sequence:     uint64(i + 1),
+			Data:         data,
+			ReceivedAt:   base.Add(time.Duration(i) * 100 * time.Millisecond),
+		})
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+
+	replayer := NewFeedReplayer(bytes.NewReader(buf.Bytes()))
+	replayer.Speed = 10
+
+	var envelopes []*Envelope
+	replayer.SetEnvelopeHandler(func(env *Envelope) {
+		envelopes = append(envelopes, env)
+	})
+
+	start := time.Now()
+	if err := replayer.Listen(); err != nil {
+		t.Fatalf("Replay returned an error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// 200ms of recorded traffic at ten times the speed.
+	if elapsed < 20*time.Millisecond || elapsed > 150*time.Millisecond {
+		t.Errorf("Expected replay to take about 20ms, took %v", elapsed)
+	}
+	if len(envelopes) != 3 {
+		t.Fatalf("Expected 3 envelopes, got %d", len(envelopes))
+	}
+	last := envelopes[2]
+	if last.Sequence != 3 || last.ConnectionID != "conn-1" || !last.ReceivedAt.Equal(base.Add(200*time.Millisecond)) {
+		t.Errorf("Unexpected envelope metadata: %+v", last)
+	}
+}
+
+func TestFeedRecorder_KeepsInvalidFrames(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewFeedRecorder(&buf)
+
+	frames := []string{
+		`{"marketId":"market-1"}`,
+		`{"marketId":`,
+		`{"marketId":"market-2"}`,
+	}
+	for i, frame := range frames {
+		recorder.Record(RawMessage{FeedType: TokenEvents, Sequence: uint64(i + 1), Data: []byte(frame)})
+	}
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("Expected invalid frames to be recorded, got %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(frames) {
+		t.Fatalf("Expected %d recorded lines, got %d", len(frames), lines)
+	}
+
+	replayer := NewFeedReplayer(bytes.NewReader(buf.Bytes()))
+	replayer.Speed = 0
+	if err := replayer.Listen(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected the invalid frame to stop the replay, got %v", err)
+	}
+
+	var markets []string
+	var invalid []string
+	replayer = NewFeedReplayer(bytes.NewReader(buf.Bytes()))
+	replayer.Speed = 0
+	replayer.ErrorPolicy = ErrorPolicySkip
+	replayer.SetErrorHandler(func(message []byte, err error) {
+		invalid = append(invalid, string(message))
+	})
+	replayer.SetMessageHandler(func(data interface{}) {
+		markets = append(markets, data.(*TokenPair).MarketId)
+	})
+	if err := replayer.Listen(); err != nil {
+		t.Fatalf("Replay returned an error: %v", err)
+	}
+	if !reflect.DeepEqual(markets, []string{"market-1", "market-2"}) {
+		t.Errorf("Expected both valid frames, got %v", markets)
+	}
+	if !reflect.DeepEqual(invalid, []string{frames[1]}) {
+		t.Errorf("Expected the invalid frame to be reported as recorded, got %q", invalid)
+	}
+}
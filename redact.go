@@ -0,0 +1,64 @@
+package vyperclientgo
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// secretParams are query parameters whose values are never reported.
+var secretParams = map[string]bool{
+	"apikey":        true,
+	"api_key":       true,
+	"key":           true,
+	"secret":        true,
+	"password":      true,
+	"signature":     true,
+	"authorization": true,
+	"access_token":  true,
+}
+
+// redactURL returns u as a string with the API key query parameter masked.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	if q.Get("apiKey") == "" {
+		return u.String()
+	}
+
+	masked := *u
+	q.Set("apiKey", redacted)
+	masked.RawQuery = q.Encode()
+	return masked.String()
+}
+
+// redactSecret masks every occurrence of secret in s, both verbatim and in
+// its query-escaped form.
+func redactSecret(s, secret string) string {
+	if secret == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, secret, redacted)
+	if escaped := url.QueryEscape(secret); escaped != secret {
+		s = strings.ReplaceAll(s, escaped, redacted)
+	}
+	return s
+}
+
+// redactParams copies params with secret parameters and any occurrence of
+// the API key masked.
+func redactParams(params map[string]string, apiKey string) map[string]string {
+	if params == nil {
+		return nil
+	}
+
+	masked := make(map[string]string, len(params))
+	for key, value := range params {
+		if secretParams[strings.ToLower(key)] {
+			masked[key] = redacted
+		} else {
+			masked[key] = redactSecret(value, apiKey)
+		}
+	}
+	return masked
+}
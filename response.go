@@ -0,0 +1,110 @@
+package vyperclientgo
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ResponseMeta describes a REST response. Latency covers sending the request
+// and reading the whole body.
+type ResponseMeta struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Header     http.Header
+	RequestID  string
+	RateLimit  *RateLimit
+	Latency    time.Duration
+	Attempt    int
+}
+
+// RateLimit is parsed from the X-RateLimit-* and Retry-After headers. Values
+// the server did not send are left at zero. Retry-After is accepted in seconds
+// or as an HTTP date.
+type RateLimit struct {
+	Limit      int
+	Remaining  int
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+// ResponseInspector is called with the metadata of every REST response,
+// including error responses, before the result is returned.
+type ResponseInspector func(*ResponseMeta)
+
+// epochThreshold separates reset values given as Unix timestamps from values
+// given in seconds from now.
+const epochThreshold = 1_000_000_000
+
+func newResponseMeta(method, endpoint string, resp *http.Response, latency time.Duration, attempt int) *ResponseMeta {
+	return &ResponseMeta{
+		Method:     method,
+		Endpoint:   endpoint,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		RateLimit:  parseRateLimit(resp.Header, time.Now()),
+		Latency:    latency,
+		Attempt:    attempt,
+	}
+}
+
+// parseRateLimit returns nil when the response carries no rate-limit headers.
+func parseRateLimit(header http.Header, now time.Time) *RateLimit {
+	limit, hasLimit := headerInt(header, "X-RateLimit-Limit")
+	remaining, hasRemaining := headerInt(header, "X-RateLimit-Remaining")
+	reset, hasReset := headerInt(header, "X-RateLimit-Reset")
+	retryAfter, hasRetryAfter := parseRetryAfter(header.Get("Retry-After"), now)
+	if !hasLimit && !hasRemaining && !hasReset && !hasRetryAfter {
+		return nil
+	}
+
+	rl := &RateLimit{
+		Limit:      limit,
+		Remaining:  remaining,
+		RetryAfter: retryAfter,
+	}
+	if hasReset {
+		if reset >= epochThreshold {
+			rl.Reset = time.Unix(int64(reset), 0)
+		} else {
+			rl.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+	return rl
+}
+
+// parseRetryAfter reads a Retry-After value given either in seconds or as an
+// HTTP date. A date in the past means no wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+func headerInt(header http.Header, key string) (int, bool) {
+	value := header.Get(key)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,101 @@
+package vyperclientgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseInspector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", "7")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		if r.URL.Path == "/api/v1/token/ath" {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":"error","message":"slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"success","message":"ok","data":{"solana":900}}`))
+	}))
+	defer server.Close()
+
+	var metas []*ResponseMeta
+	client := &VyperClient{
+		BaseURL:    server.URL,
+		ApiKey:     "test-api-key",
+		HttpClient: server.Client(),
+		ResponseInspector: func(meta *ResponseMeta) {
+			metas = append(metas, meta)
+		},
+	}
+
+	before := time.Now()
+	if _, err := client.GetChainIds(); err != nil {
+		t.Fatalf("GetChainIds returned an error: %v", err)
+	}
+	if _, err := client.GetTokenAth(900, "market-1"); err == nil {
+		t.Fatal("Expected GetTokenAth to fail")
+	}
+
+	if len(metas) != 2 {
+		t.Fatalf("Expected 2 inspected responses, got %d", len(metas))
+	}
+
+	ok := metas[0]
+	if ok.Method != "GET" || ok.Endpoint != "/api/v1/chain/ids" || ok.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected response metadata: %+v", ok)
+	}
+	if ok.RequestID != "req-1" || ok.Header.Get("X-RateLimit-Limit") != "100" || ok.Latency <= 0 || ok.Attempt != 1 {
+		t.Errorf("Unexpected response metadata: %+v", ok)
+	}
+	if ok.RateLimit == nil || ok.RateLimit.Limit != 100 || ok.RateLimit.Remaining != 7 {
+		t.Fatalf("Unexpected rate limit: %+v", ok.RateLimit)
+	}
+	if reset := ok.RateLimit.Reset.Sub(before); reset < 30*time.Second || reset > 35*time.Second {
+		t.Errorf("Expected the reset about 30s from now, got %v", reset)
+	}
+
+	limited := metas[1]
+	if limited.StatusCode != http.StatusTooManyRequests || limited.RateLimit.RetryAfter != 2*time.Second {
+		t.Errorf("Unexpected metadata for the rate-limited response: %+v, %+v", limited, limited.RateLimit)
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	if rl := parseRateLimit(http.Header{}, now); rl != nil {
+		t.Errorf("Expected nil without rate-limit headers, got %+v", rl)
+	}
+
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset", "1700000060")
+	rl := parseRateLimit(header, now)
+	if rl == nil || rl.Remaining != 0 || !rl.Reset.Equal(time.Unix(1_700_000_060, 0)) {
+		t.Errorf("Expected an epoch reset, got %+v", rl)
+	}
+
+	header.Set("X-RateLimit-Reset", "soon")
+	if rl := parseRateLimit(header, now); rl == nil || !rl.Reset.IsZero() {
+		t.Errorf("Expected an unparseable reset to be ignored, got %+v", rl)
+	}
+
+	header = http.Header{}
+	header.Set("Retry-After", now.Add(90*time.Second).UTC().Format(http.TimeFormat))
+	if rl := parseRateLimit(header, now); rl == nil || rl.RetryAfter != 90*time.Second {
+		t.Errorf("Expected a Retry-After date 90s ahead, got %+v", rl)
+	}
+	header.Set("Retry-After", now.Add(-time.Minute).UTC().Format(http.TimeFormat))
+	if rl := parseRateLimit(header, now); rl == nil || rl.RetryAfter != 0 {
+		t.Errorf("Expected a past Retry-After date to mean no wait, got %+v", rl)
+	}
+	header.Set("Retry-After", "1.5")
+	if rl := parseRateLimit(header, now); rl == nil || rl.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("Expected Retry-After in seconds, got %+v", rl)
+	}
+}
@@ -0,0 +1,151 @@
+package vyperclientgo
+
+import "time"
+
+// ConnectionState is the lifecycle state of a VyperWebsocketClient. Managed
+// clients move through StateReconnecting while they redial a dropped feed.
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateReconnecting
+	StateClosing
+	StateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosing:
+		return "closing"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// StateChange describes a transition of the connection state. Cause is the
+// error that triggered it, if any.
+type StateChange struct {
+	From  ConnectionState
+	To    ConnectionState
+	Cause error
+	At    time.Time
+}
+
+type StateChangeHandler func(StateChange)
+
+func (c *VyperWebsocketClient) State() ConnectionState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	return c.state
+}
+
+func (c *VyperWebsocketClient) SetStateChangeHandler(handler StateChangeHandler) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.stateHandler = handler
+}
+
+// setState records a transition. Handlers are not called here but from
+// notifyStateChanges, once the caller has released its locks.
+func (c *VyperWebsocketClient) setState(to ConnectionState, cause error) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.setStateLocked(to, cause)
+}
+
+func (c *VyperWebsocketClient) setStateLocked(to ConnectionState, cause error) {
+	if c.state == to {
+		return
+	}
+	c.pendingChanges = append(c.pendingChanges, StateChange{
+		From:  c.state,
+		To:    to,
+		Cause: cause,
+		At:    time.Now(),
+	})
+	c.state = to
+}
+
+// notifyStateChanges delivers recorded transitions in order. A handler that
+// changes the state again has its transitions delivered by the same loop.
+func (c *VyperWebsocketClient) notifyStateChanges() {
+	c.stateMu.Lock()
+	if c.notifying {
+		c.stateMu.Unlock()
+		return
+	}
+	c.notifying = true
+
+	for len(c.pendingChanges) > 0 {
+		changes := c.pendingChanges
+		c.pendingChanges = nil
+		handler := c.stateHandler
+		c.stateMu.Unlock()
+
+		if handler != nil {
+			for _, change := range changes {
+				handler(change)
+			}
+		}
+
+		c.stateMu.Lock()
+	}
+
+	c.notifying = false
+	c.stateMu.Unlock()
+}
+
+// beginReconnect is used by the managed clients to drop a failed connection
+// before redialling it. Unlike Disconnect it leaves the client reconnecting.
+func (c *VyperWebsocketClient) beginReconnect(cause error) {
+	defer c.notifyStateChanges()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setState(StateReconnecting, cause)
+	if c.Conn != nil {
+		c.closeConn()
+	}
+}
+
+// giveUp drops the connection of a managed client that will not redial, and
+// leaves it disconnected with cause.
+func (c *VyperWebsocketClient) giveUp(cause error) {
+	defer c.notifyStateChanges()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setState(StateDisconnected, cause)
+	if c.Conn != nil {
+		c.closeConn()
+	}
+	c.closeHandles()
+}
+
+// abandonReconnect marks a client closed that was stopped between redials.
+func (c *VyperWebsocketClient) abandonReconnect() {
+	c.mu.Lock()
+	c.closeHandles()
+	c.mu.Unlock()
+
+	c.stateMu.Lock()
+	if c.state == StateReconnecting {
+		c.setStateLocked(StateClosed, nil)
+	}
+	c.stateMu.Unlock()
+	c.notifyStateChanges()
+}
@@ -0,0 +1,213 @@
+package vyperclientgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type stateRecorder struct {
+	mu      sync.Mutex
+	changes []StateChange
+}
+
+func (r *stateRecorder) record(change StateChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.changes = append(r.changes, change)
+}
+
+func (r *stateRecorder) states() []ConnectionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var states []ConnectionState
+	for _, change := range r.changes {
+		states = append(states, change.To)
+	}
+	return states
+}
+
+func TestVyperWebsocketClient_StateTransitions(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	recorder := &stateRecorder{}
+	client.SetStateChangeHandler(recorder.record)
+
+	if got := client.State(); got != StateDisconnected {
+		t.Errorf("Expected a new client to be disconnected, got %s", got)
+	}
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	if got := client.State(); got != StateConnected {
+		t.Errorf("Expected connected, got %s", got)
+	}
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Failed to disconnect: %v", err)
+	}
+
+	expected := []ConnectionState{StateConnecting, StateConnected, StateClosing, StateClosed}
+	if got := recorder.states(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected transitions %v, got %v", expected, got)
+	}
+	if first := recorder.changes[0]; first.From != StateDisconnected || first.At.IsZero() {
+		t.Errorf("Unexpected first transition: %+v", first)
+	}
+}
+
+func TestVyperWebsocketClient_StateCause(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "restarting"))
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	recorder := &stateRecorder{}
+	client.SetStateChangeHandler(recorder.record)
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	listenErr := client.Listen()
+
+	if got := client.State(); got != StateDisconnected {
+		t.Fatalf("Expected disconnected after the server closed, got %s", got)
+	}
+	last := recorder.changes[len(recorder.changes)-1]
+	if last.From != StateConnected || last.Cause != listenErr {
+		t.Errorf("Expected the Listen error as cause, got %+v", last)
+	}
+	client.Disconnect()
+
+	client.BaseURL = "ws://127.0.0.1:1"
+	recorder.changes = nil
+	if err := client.Connect(TokenEvents); err == nil {
+		t.Fatal("Expected connecting to a closed port to fail")
+	}
+	if got := recorder.states(); !reflect.DeepEqual(got, []ConnectionState{StateConnecting, StateDisconnected}) {
+		t.Errorf("Unexpected transitions for a failed connect: %v", got)
+	}
+	if recorder.changes[1].Cause == nil {
+		t.Error("Expected the dial error as cause")
+	}
+}
+
+func TestVyperWebsocketClient_StateHandlerReentrant(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	var states []ConnectionState
+	client.SetStateChangeHandler(func(change StateChange) {
+		states = append(states, client.State())
+		// Disconnecting from inside the handler must not deadlock.
+		if change.To == StateConnected {
+			client.Disconnect()
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Connect(TokenEvents)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connect deadlocked in the state handler")
+	}
+
+	if got := client.State(); got != StateClosed {
+		t.Errorf("Expected closed, got %s", got)
+	}
+	if len(states) != 4 {
+		t.Errorf("Expected 4 transitions delivered in order, got %v", states)
+	}
+}
+
+func TestVyperMultiFeedClient_ReconnectingState(t *testing.T) {
+	var connections int32
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		if atomic.AddInt32(&connections, 1) == 1 {
+			// Drop the first connection straight away.
+			return
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	recorder := &stateRecorder{}
+	client := NewVyperMultiFeedClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ReconnectDelay = 10 * time.Millisecond
+	client.ConfigureClient = func(c *VyperWebsocketClient) {
+		c.SetStateChangeHandler(recorder.record)
+	}
+
+	if err := client.Connect(TokenEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(recorder.states()) < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the reconnect, got %v", recorder.states())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := client.States()[TokenEvents]; got != StateConnected {
+		t.Errorf("Expected the feed to be connected again, got %s", got)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	expected := []ConnectionState{
+		StateConnecting, StateConnected, StateDisconnected, StateReconnecting, StateConnected,
+		StateClosing, StateClosed,
+	}
+	if got := recorder.states(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected transitions %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,356 @@
+package vyperclientgo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+const subscriptionBufferSize = 64
+
+// Subscription is a handle on part of a shared connection. It receives only
+// the events matching its own wallets or token types. The server-side
+// subscription is kept for as long as at least one handle needs it.
+//
+// The event channel is closed by Unsubscribe and when the connection is
+// disconnected, closed or lost. Managed clients keep their handles open while
+// they redial and resubscribe them on the new connection.
+//
+// A handle whose channel is full does not hold up the connection: events that
+// do not fit are dropped for that handle and counted by Dropped.
+type Subscription struct {
+	client    *VyperWebsocketClient
+	types     []SubscriptionType
+	wallets   map[string]struct{}
+	events    chan interface{}
+	dropped   atomic.Uint64
+	closeOnce sync.Once
+}
+
+func (s *Subscription) Events() <-chan interface{} {
+	return s.events
+}
+
+// Dropped returns the number of events discarded because the handle's
+// channel was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) TokenTypes() []SubscriptionType {
+	return append([]SubscriptionType(nil), s.types...)
+}
+
+func (s *Subscription) Wallets() []string {
+	wallets := make([]string, 0, len(s.wallets))
+	for wallet := range s.wallets {
+		wallets = append(wallets, wallet)
+	}
+	return wallets
+}
+
+// Unsubscribe closes the handle's event channel and sends the server-side
+// unsubscribe for any wallet or token type no other handle still uses.
+func (s *Subscription) Unsubscribe() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.client.releaseHandle(s)
+	})
+	return err
+}
+
+func (s *Subscription) Close() error {
+	return s.Unsubscribe()
+}
+
+func (s *Subscription) matches(data interface{}) bool {
+	switch event := data.(type) {
+	case *ChainAction:
+		_, ok := s.wallets[event.Signer]
+		return ok
+	case *TokenPair:
+		for _, subscriptionType := range s.types {
+			if matchesTokenType(subscriptionType, event.TokenType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchesTokenType accepts both the subscription name ("PumpfunTokens") and
+// its short form ("Pumpfun") as the token type reported on events.
+func matchesTokenType(subscriptionType SubscriptionType, tokenType string) bool {
+	name := string(subscriptionType)
+	return strings.EqualFold(tokenType, name) || strings.EqualFold(tokenType, strings.TrimSuffix(name, "Tokens"))
+}
+
+func (c *VyperWebsocketClient) WatchTokenTypes(types ...SubscriptionType) (*Subscription, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no token types given")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.handleTypeRefs == nil {
+		c.handleTypeRefs = make(map[SubscriptionType]int)
+	}
+
+	var added []SubscriptionType
+	for _, subscriptionType := range types {
+		if c.handleTypeRefs[subscriptionType] == 0 {
+			added = append(added, subscriptionType)
+		}
+	}
+	if err := c.updateTokenTypesLocked(Subscribe, added); err != nil {
+		return nil, err
+	}
+
+	for _, subscriptionType := range types {
+		c.handleTypeRefs[subscriptionType]++
+	}
+
+	sub := c.newHandle()
+	sub.types = append(sub.types, types...)
+	c.addHandle(sub)
+	return sub, nil
+}
+
+func (c *VyperWebsocketClient) WatchWallets(wallets ...string) (*Subscription, error) {
+	if len(wallets) == 0 {
+		return nil, fmt.Errorf("no wallets given")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.handleWalletRefs == nil {
+		c.handleWalletRefs = make(map[string]int)
+	}
+
+	var added []string
+	for _, wallet := range wallets {
+		if c.handleWalletRefs[wallet] == 0 {
+			added = append(added, wallet)
+		}
+	}
+	if err := c.updateWalletsLocked(Subscribe, added); err != nil {
+		return nil, err
+	}
+
+	sub := c.newHandle()
+	for _, wallet := range wallets {
+		if _, ok := sub.wallets[wallet]; ok {
+			continue
+		}
+		sub.wallets[wallet] = struct{}{}
+		c.handleWalletRefs[wallet]++
+	}
+	c.addHandle(sub)
+	return sub, nil
+}
+
+func (c *VyperWebsocketClient) newHandle() *Subscription {
+	return &Subscription{
+		client:  c,
+		wallets: make(map[string]struct{}),
+		events:  make(chan interface{}, subscriptionBufferSize),
+	}
+}
+
+func (c *VyperWebsocketClient) addHandle(sub *Subscription) {
+	c.handlesMu.Lock()
+	defer c.handlesMu.Unlock()
+
+	if c.handles == nil {
+		c.handles = make(map[*Subscription]struct{})
+	}
+	c.handles[sub] = struct{}{}
+}
+
+func (c *VyperWebsocketClient) releaseHandle(sub *Subscription) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlesMu.Lock()
+	_, open := c.handles[sub]
+	if open {
+		delete(c.handles, sub)
+		close(sub.events)
+	}
+	c.handlesMu.Unlock()
+
+	// A handle ended with its connection has nothing left to release.
+	if !open {
+		return nil
+	}
+
+	var types []SubscriptionType
+	for _, subscriptionType := range sub.types {
+		c.handleTypeRefs[subscriptionType]--
+		if c.handleTypeRefs[subscriptionType] == 0 {
+			delete(c.handleTypeRefs, subscriptionType)
+			types = append(types, subscriptionType)
+		}
+	}
+
+	var wallets []string
+	for wallet := range sub.wallets {
+		c.handleWalletRefs[wallet]--
+		if c.handleWalletRefs[wallet] == 0 {
+			delete(c.handleWalletRefs, wallet)
+			wallets = append(wallets, wallet)
+		}
+	}
+
+	// Without a connection there is nothing subscribed server-side.
+	if c.Conn == nil {
+		return nil
+	}
+	types = c.withoutDirectTypes(types)
+	wallets = c.withoutDirectWallets(wallets)
+	if len(types) > 0 {
+		return c.updateTokenTypesLocked(Unsubscribe, types)
+	}
+	if len(wallets) > 0 {
+		return c.updateWalletsLocked(Unsubscribe, wallets)
+	}
+	return nil
+}
+
+// trackDirectTypes records token types subscribed without a handle, which
+// releasing a handle must not unsubscribe. The caller must hold c.mu.
+func (c *VyperWebsocketClient) trackDirectTypes(action SubscriptionMessageType, types []SubscriptionType) {
+	for _, subscriptionType := range types {
+		if action == Subscribe {
+			c.directTypes[subscriptionType] = struct{}{}
+		} else {
+			delete(c.directTypes, subscriptionType)
+		}
+	}
+}
+
+func (c *VyperWebsocketClient) trackDirectWallets(action SubscriptionMessageType, wallets []string) {
+	for _, wallet := range wallets {
+		if action == Subscribe {
+			c.directWallets[wallet] = struct{}{}
+		} else {
+			delete(c.directWallets, wallet)
+		}
+	}
+}
+
+func (c *VyperWebsocketClient) withoutDirectTypes(types []SubscriptionType) []SubscriptionType {
+	var pending []SubscriptionType
+	for _, subscriptionType := range types {
+		if _, ok := c.directTypes[subscriptionType]; !ok {
+			pending = append(pending, subscriptionType)
+		}
+	}
+	return pending
+}
+
+func (c *VyperWebsocketClient) withoutDirectWallets(wallets []string) []string {
+	var pending []string
+	for _, wallet := range wallets {
+		if _, ok := c.directWallets[wallet]; !ok {
+			pending = append(pending, wallet)
+		}
+	}
+	return pending
+}
+
+func (c *VyperWebsocketClient) withoutHandleTypes(types []SubscriptionType) []SubscriptionType {
+	var pending []SubscriptionType
+	for _, subscriptionType := range types {
+		if c.handleTypeRefs[subscriptionType] == 0 {
+			pending = append(pending, subscriptionType)
+		}
+	}
+	return pending
+}
+
+func (c *VyperWebsocketClient) withoutHandleWallets(wallets []string) []string {
+	var pending []string
+	for _, wallet := range wallets {
+		if c.handleWalletRefs[wallet] == 0 {
+			pending = append(pending, wallet)
+		}
+	}
+	return pending
+}
+
+// closeHandles closes the event channel of every open handle once the
+// connection is gone for good. The server-side subscriptions went with it, so
+// the reference counts are cleared too. The caller must hold c.mu.
+func (c *VyperWebsocketClient) closeHandles() {
+	c.handlesMu.Lock()
+	defer c.handlesMu.Unlock()
+
+	for sub := range c.handles {
+		close(sub.events)
+	}
+	c.handles = nil
+	c.handleTypeRefs = nil
+	c.handleWalletRefs = nil
+}
+
+// listenLost ends the handles after Listen on conn lost the connection,
+// unless a managed client is going to redial it.
+func (c *VyperWebsocketClient) listenLost(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Conn == conn && !c.managed {
+		c.closeHandles()
+	}
+}
+
+// restoreHandleSubscriptions resubscribes everything held by open handles
+// after a new connection is made. The caller must hold c.mu.
+func (c *VyperWebsocketClient) restoreHandleSubscriptions() error {
+	switch c.CurrentFeedType {
+	case TokenEvents, MigrationEvents:
+		var types []SubscriptionType
+		for subscriptionType := range c.handleTypeRefs {
+			types = append(types, subscriptionType)
+		}
+		return c.updateTokenTypesLocked(Subscribe, types)
+	case WalletEvents:
+		var wallets []string
+		for wallet := range c.handleWalletRefs {
+			wallets = append(wallets, wallet)
+		}
+		return c.updateWalletsLocked(Subscribe, wallets)
+	}
+	return nil
+}
+
+func (c *VyperWebsocketClient) hasHandles() bool {
+	c.handlesMu.RLock()
+	defer c.handlesMu.RUnlock()
+
+	return len(c.handles) > 0
+}
+
+// dispatchToHandles never blocks, so a handle nobody reads cannot stall the
+// read loop or the other handles.
+func (c *VyperWebsocketClient) dispatchToHandles(data interface{}) {
+	c.handlesMu.RLock()
+	defer c.handlesMu.RUnlock()
+
+	for sub := range c.handles {
+		if !sub.matches(data) {
+			continue
+		}
+		select {
+		case sub.events <- data:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
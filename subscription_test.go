@@ -0,0 +1,423 @@
+package vyperclientgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscription_FiltersEventsPerHandle(t *testing.T) {
+	messages := make(chan WalletSubscriptionMessage, 10)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message WalletSubscriptionMessage
+			json.Unmarshal(data, &message)
+			messages <- message
+
+			if message.Action != Subscribe {
+				continue
+			}
+			for _, wallet := range message.Wallets {
+				event, _ := json.Marshal(ChainAction{Signer: wallet, TransactionId: "tx-" + wallet})
+				c.WriteMessage(websocket.TextMessage, event)
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(WalletEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	go client.Listen()
+
+	subA, err := client.WatchWallets("wallet-a")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-a: %v", err)
+	}
+	subB, err := client.WatchWallets("wallet-b")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-b: %v", err)
+	}
+	// Sharing wallet-a with subA must not resubscribe it server-side.
+	subShared, err := client.WatchWallets("wallet-a")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-a again: %v", err)
+	}
+
+	expectEvent := func(sub *Subscription, signer string) {
+		t.Helper()
+		select {
+		case data := <-sub.Events():
+			action, ok := data.(*ChainAction)
+			if !ok || action.Signer != signer {
+				t.Errorf("Expected event for %s, got %+v", signer, data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for event for %s", signer)
+		}
+	}
+	expectEvent(subA, "wallet-a")
+	expectEvent(subB, "wallet-b")
+
+	expectMessage := func(want WalletSubscriptionMessage) {
+		t.Helper()
+		select {
+		case got := <-messages:
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected message %+v, got %+v", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %+v", want)
+		}
+	}
+	expectMessage(WalletSubscriptionMessage{Action: Subscribe, Wallets: []string{"wallet-a"}})
+	expectMessage(WalletSubscriptionMessage{Action: Subscribe, Wallets: []string{"wallet-b"}})
+
+	if err := subA.Close(); err != nil {
+		t.Fatalf("Failed to close subA: %v", err)
+	}
+	if _, ok := <-subA.Events(); ok {
+		t.Error("Expected subA events channel to be closed")
+	}
+	if err := subShared.Close(); err != nil {
+		t.Fatalf("Failed to close subShared: %v", err)
+	}
+	expectMessage(WalletSubscriptionMessage{Action: Unsubscribe, Wallets: []string{"wallet-a"}})
+
+	if got := client.SubscribedWallets(); !reflect.DeepEqual(got, []string{"wallet-b"}) {
+		t.Errorf("Unexpected subscribed wallets: %v", got)
+	}
+}
+
+func TestSubscription_ValidatesFeed(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	if _, err := client.WatchWallets("wallet-a"); err == nil {
+		t.Error("Expected error watching wallets on token-events")
+	}
+
+	sub, err := client.WatchTokenTypes(PumpfunTokens)
+	if err != nil {
+		t.Fatalf("Failed to watch token types: %v", err)
+	}
+	defer sub.Close()
+
+	if !sub.matches(&TokenPair{TokenType: "Pumpfun"}) {
+		t.Error("Expected handle to match Pumpfun token pairs")
+	}
+	if sub.matches(&TokenPair{TokenType: "RaydiumAmm"}) {
+		t.Error("Expected handle not to match RaydiumAmm token pairs")
+	}
+}
+
+func TestSubscription_UnreadHandleDoesNotBlock(t *testing.T) {
+	const count = 200
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+		for i := 0; i < count; i++ {
+			event, _ := json.Marshal(ChainAction{Signer: "wallet-a", TransactionId: fmt.Sprintf("tx-%d", i)})
+			if err := c.WriteMessage(websocket.TextMessage, event); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	delivered := make(chan struct{}, count)
+	client.SetMessageHandler(func(data interface{}) {
+		delivered <- struct{}{}
+	})
+
+	if err := client.Connect(WalletEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	sub, err := client.WatchWallets("wallet-a")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-a: %v", err)
+	}
+	defer sub.Close()
+
+	go client.Listen()
+
+	for i := 0; i < count; i++ {
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The unread handle stalled delivery after %d events", i)
+		}
+	}
+	// The handles get each event after the message handler.
+	deadline := time.Now().Add(time.Second)
+	for sub.Dropped() < count-subscriptionBufferSize && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := len(sub.Events()); got != subscriptionBufferSize {
+		t.Errorf("Expected a full handle channel, got %d events", got)
+	}
+	if got := sub.Dropped(); got != count-subscriptionBufferSize {
+		t.Errorf("Expected %d dropped events, got %d", count-subscriptionBufferSize, got)
+	}
+}
+
+func TestSubscription_ClosedWithConnection(t *testing.T) {
+	teardowns := map[string]func(client *VyperWebsocketClient, drop chan struct{}){
+		"disconnect": func(client *VyperWebsocketClient, drop chan struct{}) {
+			client.Disconnect()
+		},
+		"close": func(client *VyperWebsocketClient, drop chan struct{}) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			client.Close(ctx)
+		},
+		"connection lost": func(client *VyperWebsocketClient, drop chan struct{}) {
+			close(drop)
+		},
+	}
+
+	for name, teardown := range teardowns {
+		t.Run(name, func(t *testing.T) {
+			drop := make(chan struct{})
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer c.Close()
+
+				go func() {
+					<-drop
+					c.Close()
+				}()
+				for {
+					if _, _, err := c.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}))
+			defer s.Close()
+
+			client := NewVyperWebsocketClient("test-api-key")
+			client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+			if err := client.Connect(WalletEvents); err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer client.Disconnect()
+
+			sub, err := client.WatchWallets("wallet-a")
+			if err != nil {
+				t.Fatalf("Failed to watch wallet-a: %v", err)
+			}
+			go client.Listen()
+
+			teardown(client, drop)
+
+			ended := make(chan struct{})
+			go func() {
+				for range sub.Events() {
+				}
+				close(ended)
+			}()
+			select {
+			case <-ended:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected the handle's channel to be closed")
+			}
+			if err := sub.Close(); err != nil {
+				t.Errorf("Expected closing an ended handle to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSubscription_KeepsDirectSubscriptions(t *testing.T) {
+	messages := make(chan WalletSubscriptionMessage, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message WalletSubscriptionMessage
+			json.Unmarshal(data, &message)
+			messages <- message
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	if err := client.Connect(WalletEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	if err := client.SubscribeWallets("wallet-a"); err != nil {
+		t.Fatalf("Failed to subscribe wallet-a: %v", err)
+	}
+	subA, err := client.WatchWallets("wallet-a")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-a: %v", err)
+	}
+	if err := subA.Close(); err != nil {
+		t.Fatalf("Failed to close subA: %v", err)
+	}
+
+	subB, err := client.WatchWallets("wallet-b")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-b: %v", err)
+	}
+	defer subB.Close()
+	if err := client.UnsubscribeWallets("wallet-b"); err != nil {
+		t.Fatalf("Failed to unsubscribe wallet-b: %v", err)
+	}
+
+	// wallet-c marks the end, as the server reads messages in order.
+	if err := client.SubscribeWallets("wallet-c"); err != nil {
+		t.Fatalf("Failed to subscribe wallet-c: %v", err)
+	}
+
+	want := []WalletSubscriptionMessage{
+		{Action: Subscribe, Wallets: []string{"wallet-a"}},
+		{Action: Subscribe, Wallets: []string{"wallet-b"}},
+		{Action: Subscribe, Wallets: []string{"wallet-c"}},
+	}
+	for _, w := range want {
+		select {
+		case got := <-messages:
+			if !reflect.DeepEqual(got, w) {
+				t.Errorf("Expected message %+v, got %+v", w, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %+v", w)
+		}
+	}
+
+	if got := client.SubscribedWallets(); !reflect.DeepEqual(got, []string{"wallet-a", "wallet-b", "wallet-c"}) {
+		t.Errorf("Expected both wallets to stay subscribed, got %v", got)
+	}
+}
+
+func TestSubscription_RawUnsubscribeKeepsHandles(t *testing.T) {
+	messages := make(chan WalletSubscriptionMessage, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message WalletSubscriptionMessage
+			json.Unmarshal(data, &message)
+			messages <- message
+		}
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	if err := client.Connect(WalletEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	sub, err := client.WatchWallets("wallet-a")
+	if err != nil {
+		t.Fatalf("Failed to watch wallet-a: %v", err)
+	}
+	defer sub.Close()
+	if err := client.SubscribeWallets("wallet-b"); err != nil {
+		t.Fatalf("Failed to subscribe wallet-b: %v", err)
+	}
+
+	unsubscribe := &WalletSubscriptionMessage{Action: Unsubscribe, Wallets: []string{"wallet-a", "wallet-b"}}
+	if err := client.Unsubscribe(WalletEvents, unsubscribe); err != nil {
+		t.Fatalf("Failed to unsubscribe: %v", err)
+	}
+	if len(unsubscribe.Wallets) != 2 {
+		t.Errorf("Expected the caller's message to be left alone, got %v", unsubscribe.Wallets)
+	}
+
+	want := []WalletSubscriptionMessage{
+		{Action: Subscribe, Wallets: []string{"wallet-a"}},
+		{Action: Subscribe, Wallets: []string{"wallet-b"}},
+		{Action: Unsubscribe, Wallets: []string{"wallet-b"}},
+	}
+	for _, w := range want {
+		select {
+		case got := <-messages:
+			if !reflect.DeepEqual(got, w) {
+				t.Errorf("Expected message %+v, got %+v", w, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %+v", w)
+		}
+	}
+
+	if got := client.SubscribedWallets(); !reflect.DeepEqual(got, []string{"wallet-a"}) {
+		t.Errorf("Expected the watched wallet to stay subscribed, got %v", got)
+	}
+}
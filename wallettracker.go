@@ -0,0 +1,281 @@
+package vyperclientgo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultMaxWalletsPerConnection = 100
+
+type walletShard struct {
+	feed    *feedConn
+	wallets map[string]struct{}
+}
+
+func (s *walletShard) free(max int) int {
+	return max - len(s.wallets)
+}
+
+// VyperWalletTracker tracks an arbitrary number of wallets by spreading them
+// over as many wallet-events connections as MaxWalletsPerConnection requires.
+// Wallets are rebalanced as they are added and removed so that no more
+// connections are kept open than needed, and the actions of every connection
+// are merged into a single channel.
+type VyperWalletTracker struct {
+	BaseURL                 string
+	ApiKey                  string
+	Credentials             CredentialsProvider
+	MaxWalletsPerConnection int
+	PingInterval            time.Duration
+	ReconnectDelay          time.Duration
+	MaxReconnectDelay       time.Duration
+	ErrorHandler            func(error)
+	Deduplicator            *Deduplicator
+	ConfigureClient         func(client *VyperWebsocketClient)
+
+	events  chan *ChainAction
+	shards  []*walletShard
+	wallets map[string]*walletShard
+	closed  bool
+	mu      sync.Mutex
+}
+
+func NewVyperWalletTracker(apiKey string) *VyperWalletTracker {
+	return &VyperWalletTracker{
+		BaseURL:                 defaultWebsocketURL,
+		ApiKey:                  apiKey,
+		MaxWalletsPerConnection: defaultMaxWalletsPerConnection,
+		PingInterval:            defaultPingInterval,
+		ReconnectDelay:          defaultReconnectDelay,
+		MaxReconnectDelay:       defaultMaxReconnectDelay,
+		events:                  make(chan *ChainAction, 256),
+		wallets:                 make(map[string]*walletShard),
+	}
+}
+
+func (t *VyperWalletTracker) Events() <-chan *ChainAction {
+	return t.events
+}
+
+func (t *VyperWalletTracker) Wallets() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wallets := make([]string, 0, len(t.wallets))
+	for wallet := range t.wallets {
+		wallets = append(wallets, wallet)
+	}
+	sort.Strings(wallets)
+	return wallets
+}
+
+func (t *VyperWalletTracker) ConnectionCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.shards)
+}
+
+func (t *VyperWalletTracker) AddWallets(wallets ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("tracker %w", ErrClosed)
+	}
+	if t.MaxWalletsPerConnection <= 0 {
+		return fmt.Errorf("invalid MaxWalletsPerConnection: %d", t.MaxWalletsPerConnection)
+	}
+
+	var pending []string
+	seen := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		if wallet == "" {
+			return fmt.Errorf("invalid wallet address: %q", wallet)
+		}
+		if _, tracked := t.wallets[wallet]; tracked || seen[wallet] {
+			continue
+		}
+		seen[wallet] = true
+		pending = append(pending, wallet)
+	}
+
+	for _, shard := range t.shards {
+		if len(pending) == 0 {
+			return nil
+		}
+		var err error
+		pending, err = t.assign(shard, pending)
+		if err != nil {
+			return err
+		}
+	}
+
+	for len(pending) > 0 {
+		shard, err := t.openShard()
+		if err != nil {
+			return err
+		}
+		pending, err = t.assign(shard, pending)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *VyperWalletTracker) RemoveWallets(wallets ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return fmt.Errorf("tracker %w", ErrClosed)
+	}
+
+	byShard := make(map[*walletShard][]string)
+	for _, wallet := range wallets {
+		if shard, ok := t.wallets[wallet]; ok {
+			byShard[shard] = append(byShard[shard], wallet)
+		}
+	}
+
+	for shard, removed := range byShard {
+		if err := shard.feed.updateWallets(Unsubscribe, removed); err != nil {
+			return err
+		}
+		for _, wallet := range removed {
+			delete(shard.wallets, wallet)
+			delete(t.wallets, wallet)
+		}
+	}
+
+	return t.rebalance()
+}
+
+// assign subscribes as many of the pending wallets as fit on the shard and
+// returns the ones left over.
+func (t *VyperWalletTracker) assign(shard *walletShard, pending []string) ([]string, error) {
+	n := shard.free(t.MaxWalletsPerConnection)
+	if n <= 0 {
+		return pending, nil
+	}
+	if n > len(pending) {
+		n = len(pending)
+	}
+
+	chunk := pending[:n]
+	if err := shard.feed.updateWallets(Subscribe, chunk); err != nil {
+		return pending, err
+	}
+	for _, wallet := range chunk {
+		shard.wallets[wallet] = struct{}{}
+		t.wallets[wallet] = shard
+	}
+	return pending[n:], nil
+}
+
+// rebalance drains the least loaded shards into the others until no more
+// connections are open than the tracked wallets need.
+func (t *VyperWalletTracker) rebalance() error {
+	max := t.MaxWalletsPerConnection
+	needed := (len(t.wallets) + max - 1) / max
+
+	for len(t.shards) > needed {
+		sort.SliceStable(t.shards, func(i, j int) bool {
+			return len(t.shards[i].wallets) > len(t.shards[j].wallets)
+		})
+		source := t.shards[len(t.shards)-1]
+		t.shards = t.shards[:len(t.shards)-1]
+
+		pending := make([]string, 0, len(source.wallets))
+		for wallet := range source.wallets {
+			pending = append(pending, wallet)
+		}
+		sort.Strings(pending)
+
+		// Subscribe on the new shard before closing the old one so that the
+		// moved wallets are never uncovered.
+		for _, target := range t.shards {
+			var err error
+			pending, err = t.assign(target, pending)
+			if err != nil {
+				for wallet := range source.wallets {
+					if t.wallets[wallet] != source {
+						delete(source.wallets, wallet)
+					}
+				}
+				t.shards = append(t.shards, source)
+				return err
+			}
+		}
+
+		if err := source.feed.close(); err != nil {
+			t.reportError(err)
+		}
+	}
+	return nil
+}
+
+func (t *VyperWalletTracker) openShard() (*walletShard, error) {
+	client := NewVyperWebsocketClient(t.ApiKey)
+	client.BaseURL = t.BaseURL
+	client.Credentials = t.Credentials
+	client.Deduplicator = t.Deduplicator
+	if t.ConfigureClient != nil {
+		t.ConfigureClient(client)
+	}
+
+	feed := newFeedConn(client, WalletEvents)
+	feed.pingInterval = t.PingInterval
+	feed.reconnectDelay = t.ReconnectDelay
+	feed.maxReconnectDelay = t.MaxReconnectDelay
+	feed.onError = t.reportError
+
+	err := feed.start(func(env *Envelope) {
+		action, ok := env.Data.(*ChainAction)
+		if !ok {
+			return
+		}
+		select {
+		case t.events <- action:
+		case <-feed.done:
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shard := &walletShard{feed: feed, wallets: make(map[string]struct{})}
+	t.shards = append(t.shards, shard)
+	return shard, nil
+}
+
+func (t *VyperWalletTracker) reportError(err error) {
+	if t.ErrorHandler != nil {
+		t.ErrorHandler(err)
+	}
+}
+
+func (t *VyperWalletTracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	var firstErr error
+	for _, shard := range t.shards {
+		if err := shard.feed.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	t.shards = nil
+	t.wallets = make(map[string]*walletShard)
+
+	close(t.events)
+	return firstErr
+}
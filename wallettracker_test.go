@@ -0,0 +1,144 @@
+package vyperclientgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// walletServer keeps the wallets subscribed on each connection and echoes a
+// ChainAction for every newly subscribed wallet.
+type walletServer struct {
+	mu          sync.Mutex
+	connections map[*websocket.Conn]map[string]bool
+}
+
+func (ws *walletServer) handle(w http.ResponseWriter, r *http.Request) {
+	c, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	ws.mu.Lock()
+	ws.connections[c] = make(map[string]bool)
+	ws.mu.Unlock()
+
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.connections, c)
+		ws.mu.Unlock()
+	}()
+
+	for {
+		_, data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var message WalletSubscriptionMessage
+		json.Unmarshal(data, &message)
+
+		ws.mu.Lock()
+		for _, wallet := range message.Wallets {
+			ws.connections[c][wallet] = message.Action == Subscribe
+		}
+		ws.mu.Unlock()
+
+		if message.Action != Subscribe {
+			continue
+		}
+		for _, wallet := range message.Wallets {
+			event, _ := json.Marshal(ChainAction{Signer: wallet, TransactionId: "tx-" + wallet})
+			c.WriteMessage(websocket.TextMessage, event)
+		}
+	}
+}
+
+func (ws *walletServer) subscriptionCounts() []int {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var counts []int
+	for _, wallets := range ws.connections {
+		n := 0
+		for _, subscribed := range wallets {
+			if subscribed {
+				n++
+			}
+		}
+		counts = append(counts, n)
+	}
+	return counts
+}
+
+func TestVyperWalletTracker_ShardsAndRebalances(t *testing.T) {
+	ws := &walletServer{connections: make(map[*websocket.Conn]map[string]bool)}
+	s := httptest.NewServer(http.HandlerFunc(ws.handle))
+	defer s.Close()
+
+	tracker := NewVyperWalletTracker("test-api-key")
+	tracker.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	tracker.MaxWalletsPerConnection = 2
+	defer tracker.Close()
+
+	wallets := []string{"w1", "w2", "w3", "w4", "w5"}
+	if err := tracker.AddWallets(wallets...); err != nil {
+		t.Fatalf("Failed to add wallets: %v", err)
+	}
+
+	if got := tracker.ConnectionCount(); got != 3 {
+		t.Errorf("Expected 3 connections, got %d", got)
+	}
+
+	seen := make(map[string]bool)
+	for len(seen) < len(wallets) {
+		select {
+		case action := <-tracker.Events():
+			seen[action.Signer] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for actions, got %v", seen)
+		}
+	}
+
+	if err := tracker.RemoveWallets("w1", "w3", "w5"); err != nil {
+		t.Fatalf("Failed to remove wallets: %v", err)
+	}
+
+	if got := tracker.ConnectionCount(); got != 1 {
+		t.Errorf("Expected 1 connection after rebalance, got %d", got)
+	}
+	if got := tracker.Wallets(); !reflect.DeepEqual(got, []string{"w2", "w4"}) {
+		t.Errorf("Unexpected tracked wallets: %v", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		counts := ws.subscriptionCounts()
+		if reflect.DeepEqual(counts, []int{2}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the server to see one connection with 2 wallets, got %v", counts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestVyperWalletTracker_RejectsInvalidWallets(t *testing.T) {
+	tracker := NewVyperWalletTracker("test-api-key")
+	defer tracker.Close()
+
+	if err := tracker.AddWallets(""); err == nil {
+		t.Error("Expected error for empty wallet address")
+	}
+	if got := tracker.ConnectionCount(); got != 0 {
+		t.Errorf("Expected no connections, got %d", got)
+	}
+}
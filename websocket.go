@@ -1,10 +1,18 @@
 package vyperclientgo
 
 import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -33,6 +41,13 @@ const (
 	RaydiumClmmTokens SubscriptionType = "RaydiumClmmTokens"
 )
 
+var knownSubscriptionTypes = map[SubscriptionType]bool{
+	PumpfunTokens:     true,
+	RaydiumAmmTokens:  true,
+	RaydiumCpmmTokens: true,
+	RaydiumClmmTokens: true,
+}
+
 type TokenSubscriptionMessage struct {
 	Action SubscriptionMessageType `json:"action"`
 	Types  []SubscriptionType      `json:"types"`
@@ -45,166 +60,701 @@ type WalletSubscriptionMessage struct {
 
 type MessageHandler func(interface{})
 
+// RawMessage is a frame exactly as it was read from the socket. Data is not
+// reused by the client and may be retained by the handler.
+type RawMessage struct {
+	FeedType     FeedType
+	ConnectionID string
+	Sequence     uint64
+	Data         []byte
+	ReceivedAt   time.Time
+}
+
+type RawMessageHandler func(RawMessage)
+
+// Envelope wraps a delivered event with where and when it was received.
+// Sequence numbers every frame read on a connection, starting at 1, so a gap
+// means frames were dropped or skipped in between.
+type Envelope struct {
+	FeedType     FeedType
+	ConnectionID string
+	Sequence     uint64
+	ReceivedAt   time.Time
+	Size         int
+	Data         interface{}
+}
+
+type EnvelopeHandler func(*Envelope)
+
+// FrameErrorHandler receives frames that could not be decoded, together with
+// the decoding error.
+type FrameErrorHandler func(message []byte, err error)
+
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyAbort makes Listen return on the first undecodable frame.
+	ErrorPolicyAbort ErrorPolicy = iota
+	// ErrorPolicySkip reports undecodable frames and keeps reading.
+	ErrorPolicySkip
+)
+
 type VyperWebsocketClient struct {
-	BaseURL         string
-	ApiKey          string
-	Conn            *websocket.Conn
-	MessageHandler  MessageHandler
-	CurrentFeedType FeedType
-	mu              sync.Mutex
+	BaseURL           string
+	ApiKey            string
+	Credentials       CredentialsProvider
+	ApiKeyInHeader    bool
+	Dialer            *websocket.Dialer
+	Header            http.Header
+	ProxyURL          string
+	TLSConfig         *tls.Config
+	HandshakeTimeout  time.Duration
+	EnableCompression bool
+	Conn              *websocket.Conn
+	MessageHandler    MessageHandler
+	EnvelopeHandler   EnvelopeHandler
+	RawHandler        RawMessageHandler
+	ControlHandler    ControlHandler
+	ErrorHandler      FrameErrorHandler
+	ErrorPolicy       ErrorPolicy
+	CurrentFeedType   FeedType
+	QueueSize         int
+	OverflowPolicy    OverflowPolicy
+	Workers           int
+	Deduplicator      *Deduplicator
+	mu                sync.Mutex
+
+	stateMu        sync.Mutex
+	state          ConnectionState
+	stateHandler   StateChangeHandler
+	pendingChanges []StateChange
+	notifying      bool
+
+	counters     listenerCounters
+	connectionID string
+	dialedKey    string
+	sequence     atomic.Uint64
+
+	// listening counts running Listen calls and listenDone is closed when the
+	// last one returns. closing is set while Close runs. All three are
+	// guarded by mu.
+	listening  int
+	listenDone chan struct{}
+	closing    bool
+	discard    atomic.Bool
+
+	// managed is set on the clients of the managed feeds, which redial a lost
+	// connection and keep its subscription handles open.
+	managed bool
+	// pongTimeout is set by the managed feeds for the current connection.
+	pongTimeout time.Duration
+
+	subscribedTypes   map[SubscriptionType]struct{}
+	subscribedWallets map[string]struct{}
+	directTypes       map[SubscriptionType]struct{}
+	directWallets     map[string]struct{}
+
+	handleTypeRefs   map[SubscriptionType]int
+	handleWalletRefs map[string]int
+	handles          map[*Subscription]struct{}
+	handlesMu        sync.RWMutex
 }
 
 func NewVyperWebsocketClient(apiKey string) *VyperWebsocketClient {
 	return &VyperWebsocketClient{
-		BaseURL: "wss://api.vyper.trade/api/v1/ws",
+		BaseURL: defaultWebsocketURL,
 		ApiKey:  apiKey,
 	}
 }
 
 func (c *VyperWebsocketClient) Connect(feedType FeedType) error {
+	defer c.notifyStateChanges()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.Conn != nil {
-		return fmt.Errorf("already connected")
+		return ErrAlreadyConnected
 	}
 
+	// A failed redial leaves a reconnecting client reconnecting.
+	fallback := StateDisconnected
+	if c.State() == StateReconnecting {
+		fallback = StateReconnecting
+	} else {
+		c.setState(StateConnecting, nil)
+	}
+
+	if err := c.dial(feedType); err != nil {
+		c.setState(fallback, err)
+		return err
+	}
+
+	c.setState(StateConnected, nil)
+	return nil
+}
+
+// dial opens the connection and restores open subscription handles. The
+// caller must hold c.mu.
+func (c *VyperWebsocketClient) dial(feedType FeedType) error {
 	u, err := url.Parse(fmt.Sprintf("%s/%s", c.BaseURL, feedType))
 	if err != nil {
 		return err
 	}
 
-	q := u.Query()
-	q.Set("apiKey", c.ApiKey)
-	u.RawQuery = q.Encode()
-
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	apiKey, err := resolveApiKey(c.Credentials, c.ApiKey)
 	if err != nil {
-		return &VyperWebsocketError{
-			Message:        fmt.Sprintf("Failed to connect: %v", err),
-			ConnectionInfo: u.String(),
+		return err
+	}
+	c.dialedKey = apiKey
+
+	header := c.Header.Clone()
+	if c.ApiKeyInHeader {
+		if header == nil {
+			header = make(http.Header)
 		}
+		header.Set("X-API-Key", apiKey)
+	} else {
+		q := u.Query()
+		q.Set("apiKey", apiKey)
+		u.RawQuery = q.Encode()
+	}
+
+	dialer, err := c.dialer()
+	if err != nil {
+		return err
+	}
+
+	conn, resp, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		return handshakeError(err, resp, apiKey, redactURL(u))
 	}
 
 	c.Conn = conn
 	c.CurrentFeedType = feedType
+	c.connectionID = newConnectionID()
+	c.sequence.Store(0)
+	c.discard.Store(false)
+	c.subscribedTypes = make(map[SubscriptionType]struct{})
+	c.subscribedWallets = make(map[string]struct{})
+	c.directTypes = make(map[SubscriptionType]struct{})
+	c.directWallets = make(map[string]struct{})
+
+	if err := c.restoreHandleSubscriptions(); err != nil {
+		c.Conn.Close()
+		c.Conn = nil
+		c.CurrentFeedType = ""
+		return err
+	}
 	return nil
 }
 
+// Subscribe sends a subscription message as given, except that an
+// unsubscribe leaves out the token types and wallets open handles still need.
+// It returns no handle, so that existing callers keep compiling; use
+// WatchTokenTypes or WatchWallets for a Subscription with its own channel.
 func (c *VyperWebsocketClient) Subscribe(feedType FeedType, message interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.Conn == nil {
-		return fmt.Errorf("not connected")
+		return ErrNotConnected
 	}
 
 	if feedType != c.CurrentFeedType {
-		return fmt.Errorf("feed type mismatch")
+		return ErrFeedMismatch
+	}
+
+	switch m := message.(type) {
+	case *TokenSubscriptionMessage:
+		message = *m
+	case *WalletSubscriptionMessage:
+		message = *m
+	}
+
+	pending := message
+	switch m := message.(type) {
+	case TokenSubscriptionMessage:
+		if m.Action == Unsubscribe && len(m.Types) > 0 {
+			m.Types = c.withoutHandleTypes(m.Types)
+			pending = m
+			if len(m.Types) == 0 {
+				pending = nil
+			}
+		}
+	case WalletSubscriptionMessage:
+		if m.Action == Unsubscribe && len(m.Wallets) > 0 {
+			m.Wallets = c.withoutHandleWallets(m.Wallets)
+			pending = m
+			if len(m.Wallets) == 0 {
+				pending = nil
+			}
+		}
+	}
+
+	if pending != nil {
+		if err := c.send(pending); err != nil {
+			return err
+		}
+	}
+	switch m := message.(type) {
+	case TokenSubscriptionMessage:
+		c.trackDirectTypes(m.Action, m.Types)
+	case WalletSubscriptionMessage:
+		c.trackDirectWallets(m.Action, m.Wallets)
+	}
+	return nil
+}
+
+func (c *VyperWebsocketClient) Unsubscribe(feedType FeedType, message interface{}) error {
+	return c.Subscribe(feedType, message)
+}
+
+func (c *VyperWebsocketClient) SubscribeTokenTypes(types ...SubscriptionType) error {
+	return c.updateTokenTypes(Subscribe, types)
+}
+
+func (c *VyperWebsocketClient) UnsubscribeTokenTypes(types ...SubscriptionType) error {
+	return c.updateTokenTypes(Unsubscribe, types)
+}
+
+func (c *VyperWebsocketClient) SubscribeWallets(wallets ...string) error {
+	return c.updateWallets(Subscribe, wallets)
+}
+
+func (c *VyperWebsocketClient) UnsubscribeWallets(wallets ...string) error {
+	return c.updateWallets(Unsubscribe, wallets)
+}
+
+// SubscribedTokenTypes returns the token types currently subscribed on the
+// connection, sorted by name.
+func (c *VyperWebsocketClient) SubscribedTokenTypes() []SubscriptionType {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	types := make([]SubscriptionType, 0, len(c.subscribedTypes))
+	for subscriptionType := range c.subscribedTypes {
+		types = append(types, subscriptionType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// SubscribedWallets returns the wallets currently subscribed on the
+// connection, sorted by address.
+func (c *VyperWebsocketClient) SubscribedWallets() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wallets := make([]string, 0, len(c.subscribedWallets))
+	for wallet := range c.subscribedWallets {
+		wallets = append(wallets, wallet)
+	}
+	sort.Strings(wallets)
+	return wallets
+}
+
+// updateTokenTypes changes the token types subscribed directly, as opposed
+// to through a handle. Types that open handles still need stay subscribed.
+func (c *VyperWebsocketClient) updateTokenTypes(action SubscriptionMessageType, types []SubscriptionType) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := types
+	if action == Unsubscribe {
+		pending = c.withoutHandleTypes(types)
+	}
+	if err := c.updateTokenTypesLocked(action, pending); err != nil {
+		return err
+	}
+	c.trackDirectTypes(action, types)
+	return nil
+}
+
+func (c *VyperWebsocketClient) updateTokenTypesLocked(action SubscriptionMessageType, types []SubscriptionType) error {
+	if c.Conn == nil {
+		return ErrNotConnected
+	}
+
+	if c.CurrentFeedType != TokenEvents && c.CurrentFeedType != MigrationEvents {
+		return fmt.Errorf("%w: token types cannot be subscribed on %s", ErrFeedMismatch, c.CurrentFeedType)
+	}
+
+	var pending []SubscriptionType
+	seen := make(map[SubscriptionType]bool, len(types))
+	for _, subscriptionType := range types {
+		if !knownSubscriptionTypes[subscriptionType] {
+			return fmt.Errorf("unknown subscription type: %s", subscriptionType)
+		}
+		if seen[subscriptionType] {
+			continue
+		}
+		seen[subscriptionType] = true
+
+		_, subscribed := c.subscribedTypes[subscriptionType]
+		if subscribed != (action == Subscribe) {
+			pending = append(pending, subscriptionType)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return c.send(TokenSubscriptionMessage{Action: action, Types: pending})
+}
+
+// updateWallets changes the wallets subscribed directly, as opposed to
+// through a handle. Wallets that open handles still need stay subscribed.
+func (c *VyperWebsocketClient) updateWallets(action SubscriptionMessageType, wallets []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := wallets
+	if action == Unsubscribe {
+		pending = c.withoutHandleWallets(wallets)
+	}
+	if err := c.updateWalletsLocked(action, pending); err != nil {
+		return err
+	}
+	c.trackDirectWallets(action, wallets)
+	return nil
+}
+
+func (c *VyperWebsocketClient) updateWalletsLocked(action SubscriptionMessageType, wallets []string) error {
+	if c.Conn == nil {
+		return ErrNotConnected
+	}
+
+	if c.CurrentFeedType != WalletEvents {
+		return fmt.Errorf("%w: wallets cannot be subscribed on %s", ErrFeedMismatch, c.CurrentFeedType)
+	}
+
+	var pending []string
+	seen := make(map[string]bool, len(wallets))
+	for _, wallet := range wallets {
+		if wallet == "" || strings.TrimSpace(wallet) != wallet {
+			return fmt.Errorf("invalid wallet address: %q", wallet)
+		}
+		if seen[wallet] {
+			continue
+		}
+		seen[wallet] = true
+
+		_, subscribed := c.subscribedWallets[wallet]
+		if subscribed != (action == Subscribe) {
+			pending = append(pending, wallet)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
 	}
 
+	return c.send(WalletSubscriptionMessage{Action: action, Wallets: pending})
+}
+
+// send writes a subscription message and records its effect on the tracked
+// subscription state. The caller must hold c.mu.
+func (c *VyperWebsocketClient) send(message interface{}) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
-	return c.Conn.WriteMessage(websocket.TextMessage, data)
+	if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+
+	switch m := message.(type) {
+	case TokenSubscriptionMessage:
+		c.trackTokenTypes(m)
+	case *TokenSubscriptionMessage:
+		c.trackTokenTypes(*m)
+	case WalletSubscriptionMessage:
+		c.trackWallets(m)
+	case *WalletSubscriptionMessage:
+		c.trackWallets(*m)
+	}
+	return nil
 }
 
-func (c *VyperWebsocketClient) Unsubscribe(feedType FeedType, message interface{}) error {
-	return c.Subscribe(feedType, message)
+func (c *VyperWebsocketClient) trackTokenTypes(message TokenSubscriptionMessage) {
+	for _, subscriptionType := range message.Types {
+		switch message.Action {
+		case Subscribe:
+			c.subscribedTypes[subscriptionType] = struct{}{}
+		case Unsubscribe:
+			delete(c.subscribedTypes, subscriptionType)
+		}
+	}
+}
+
+func (c *VyperWebsocketClient) trackWallets(message WalletSubscriptionMessage) {
+	for _, wallet := range message.Wallets {
+		switch message.Action {
+		case Subscribe:
+			c.subscribedWallets[wallet] = struct{}{}
+		case Unsubscribe:
+			delete(c.subscribedWallets, wallet)
+		}
+	}
 }
 
 func (c *VyperWebsocketClient) Listen() error {
-	if c.Conn == nil {
-		return fmt.Errorf("not connected")
+	c.mu.Lock()
+	conn := c.Conn
+	feedType := c.CurrentFeedType
+	connectionID := c.connectionID
+	pongTimeout := c.pongTimeout
+	if conn == nil || c.closing {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+	c.listening++
+	if c.listenDone == nil {
+		c.listenDone = make(chan struct{})
+	}
+	c.mu.Unlock()
+
+	// Deferred first so they run after the queue has drained.
+	var lost bool
+	defer c.listenReturned()
+	defer func() {
+		if lost {
+			c.listenLost(conn)
+		}
+	}()
+
+	var queue *dispatcher
+	if c.QueueSize > 0 || c.Workers > 0 {
+		queue = newDispatcher(c.Workers, c.QueueSize, c.OverflowPolicy, &c.counters, c.deliver)
+		defer queue.close()
 	}
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		if pongTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		}
+		_, message, err := conn.ReadMessage()
 		if err != nil {
+			err = closeError(err, feedType)
+			c.connectionLost(conn, err)
+			lost = true
 			return err
 		}
+		receivedAt := time.Now()
+		sequence := c.sequence.Add(1)
+
+		if c.RawHandler != nil {
+			c.RawHandler(RawMessage{
+				FeedType:     feedType,
+				ConnectionID: connectionID,
+				Sequence:     sequence,
+				Data:         message,
+				ReceivedAt:   receivedAt,
+			})
+		}
 
-		if c.MessageHandler != nil {
-			var rawData map[string]interface{}
-			err = json.Unmarshal(message, &rawData)
+		if c.MessageHandler != nil || c.EnvelopeHandler != nil || c.ControlHandler != nil || c.hasHandles() {
+			data, err := decodeMessage(feedType, message)
 			if err != nil {
+				if c.ErrorHandler != nil {
+					c.ErrorHandler(message, err)
+				}
+				if c.ErrorPolicy == ErrorPolicySkip {
+					c.counters.skipped.Add(1)
+					continue
+				}
 				return err
 			}
 
-			convertedData, err := c.convertMessage(rawData)
-			if err != nil {
-				return err
+			if control, ok := data.(*ControlMessage); ok {
+				if c.ControlHandler != nil {
+					c.ControlHandler(control)
+				}
+				continue
+			}
+
+			if c.Deduplicator != nil && c.Deduplicator.Seen(data) {
+				c.counters.duplicates.Add(1)
+				continue
 			}
 
-			c.MessageHandler(convertedData)
+			env := &Envelope{
+				FeedType:     feedType,
+				ConnectionID: connectionID,
+				Sequence:     sequence,
+				ReceivedAt:   receivedAt,
+				Size:         len(message),
+				Data:         data,
+			}
+			if queue == nil {
+				c.deliver(env)
+			} else if !queue.push(env) {
+				conn.Close()
+				err := &VyperWebsocketError{Message: "message queue overflow"}
+				c.connectionLost(conn, err)
+				lost = true
+				return err
+			}
 		}
 	}
 }
 
-func (c *VyperWebsocketClient) convertMessage(data map[string]interface{}) (interface{}, error) {
-	switch c.CurrentFeedType {
-	case WalletEvents:
-		return c.convertToChainAction(data)
-	case MigrationEvents, TokenEvents:
-		return c.convertToTokenPair(data)
-	default:
-		return nil, fmt.Errorf("unknown feed type: %s", c.CurrentFeedType)
-	}
-}
+// connectionLost marks the client disconnected when conn, still the current
+// connection, fails outside of Disconnect.
+func (c *VyperWebsocketClient) connectionLost(conn *websocket.Conn, cause error) {
+	c.mu.Lock()
+	current := c.Conn == conn
+	c.mu.Unlock()
 
-func (c *VyperWebsocketClient) convertToChainAction(data map[string]interface{}) (*ChainAction, error) {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+	if !current {
+		return
 	}
 
-	var chainAction ChainAction
-	err = json.Unmarshal(jsonData, &chainAction)
-	if err != nil {
-		return nil, err
+	c.stateMu.Lock()
+	if c.state == StateConnected {
+		c.setStateLocked(StateDisconnected, cause)
 	}
+	c.stateMu.Unlock()
+	c.notifyStateChanges()
+}
+
+func (c *VyperWebsocketClient) listenReturned() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	return &chainAction, nil
+	c.listening--
+	if c.listening == 0 {
+		close(c.listenDone)
+		c.listenDone = nil
+	}
 }
 
-func (c *VyperWebsocketClient) convertToTokenPair(data map[string]interface{}) (*TokenPair, error) {
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return nil, err
+func (c *VyperWebsocketClient) deliver(env *Envelope) {
+	// Close discards what is still queued once its context expires.
+	if c.discard.Load() {
+		c.counters.dropped.Add(1)
+		return
+	}
+	if c.EnvelopeHandler != nil {
+		c.EnvelopeHandler(env)
 	}
+	if c.MessageHandler != nil {
+		c.MessageHandler(env.Data)
+	}
+	c.dispatchToHandles(env.Data)
+}
 
-	var tokenPair TokenPair
-	err = json.Unmarshal(jsonData, &tokenPair)
-	if err != nil {
-		return nil, err
+// Stats returns the counters of the listen loop and of the queue between it
+// and the handlers.
+func (c *VyperWebsocketClient) Stats() ListenerStats {
+	return ListenerStats{
+		Queued:      c.counters.queued.Load(),
+		Dropped:     c.counters.dropped.Load(),
+		Skipped:     c.counters.skipped.Load(),
+		Duplicates:  c.counters.duplicates.Load(),
+		QueueLength: int(c.counters.length.Load()),
 	}
+}
 
-	return &tokenPair, nil
+// decodeMessage decodes a frame straight into the event type of the feed.
+// Frames without the event's identifier, a market ID or a transaction ID, are
+// never market data and are returned as *ControlMessage instead. Those with
+// no recognised control key become notices.
+func decodeMessage(feedType FeedType, message []byte) (interface{}, error) {
+	switch feedType {
+	case WalletEvents:
+		var action ChainAction
+		err := json.Unmarshal(message, &action)
+		if err == nil && action.TransactionId != "" {
+			return &action, nil
+		}
+		return decodeControlMessage(feedType, message, "transactionId", err)
+	case MigrationEvents, TokenEvents:
+		var pair TokenPair
+		err := json.Unmarshal(message, &pair)
+		if err == nil && pair.MarketId != "" {
+			return &pair, nil
+		}
+		return decodeControlMessage(feedType, message, "marketId", err)
+	default:
+		return nil, fmt.Errorf("unknown feed type: %s", feedType)
+	}
 }
 
 func (c *VyperWebsocketClient) Disconnect() error {
+	defer c.notifyStateChanges()
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if c.Conn == nil {
-		return fmt.Errorf("not connected")
+		return ErrNotConnected
 	}
 
-	err := c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	if err != nil {
-		return err
-	}
+	c.setState(StateClosing, nil)
+	defer c.setState(StateClosed, nil)
 
-	err = c.Conn.Close()
-	if err != nil {
-		return err
+	c.closeHandles()
+	return c.closeConn()
+}
+
+// closeConn sends a close frame, closes the connection and clears the
+// connection state. The caller must hold c.mu.
+func (c *VyperWebsocketClient) closeConn() error {
+	writeErr := c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	closeErr := c.Conn.Close()
+	c.resetConn()
+
+	if writeErr != nil {
+		return writeErr
 	}
+	return closeErr
+}
 
+// resetConn forgets the current connection. The caller must hold c.mu.
+func (c *VyperWebsocketClient) resetConn() {
 	c.Conn = nil
 	c.CurrentFeedType = ""
-	return nil
+	c.connectionID = ""
+	c.pongTimeout = 0
+	c.subscribedTypes = nil
+	c.subscribedWallets = nil
+	c.directTypes = nil
+	c.directWallets = nil
+}
+
+// ConnectionID identifies the current connection. A new ID is assigned on
+// every Connect.
+func (c *VyperWebsocketClient) ConnectionID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.connectionID
+}
+
+func newConnectionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// String describes the client without its API key, so the client can be
+// logged safely.
+func (c *VyperWebsocketClient) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return fmt.Sprintf("VyperWebsocketClient{BaseURL: %s, Feed: %s, Connected: %t}", c.BaseURL, c.CurrentFeedType, c.Conn != nil)
+}
+
+func (c *VyperWebsocketClient) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.Conn != nil
 }
 
 func (c *VyperWebsocketClient) Ping() error {
@@ -212,12 +762,66 @@ func (c *VyperWebsocketClient) Ping() error {
 	defer c.mu.Unlock()
 
 	if c.Conn == nil {
-		return fmt.Errorf("not connected")
+		return ErrNotConnected
 	}
 
 	return c.Conn.WriteMessage(websocket.PingMessage, nil)
 }
 
+// setPongTimeout makes every read on the current connection fail after
+// timeout without a frame or pong. The deadline is armed by Listen before
+// each read, so time spent delivering events never counts against it.
+func (c *VyperWebsocketClient) setPongTimeout(timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Conn == nil {
+		return ErrNotConnected
+	}
+
+	conn := c.Conn
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+	c.pongTimeout = timeout
+	return nil
+}
+
+// keepalivePing pings the server and closes the connection if the ping cannot
+// be written within timeout, so a running Listen returns. The write happens
+// outside c.mu, which gorilla/websocket allows for control frames.
+func (c *VyperWebsocketClient) keepalivePing(timeout time.Duration) error {
+	c.mu.Lock()
+	conn := c.Conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+	}
+	return err
+}
+
 func (c *VyperWebsocketClient) SetMessageHandler(handler MessageHandler) {
 	c.MessageHandler = handler
 }
+
+func (c *VyperWebsocketClient) SetEnvelopeHandler(handler EnvelopeHandler) {
+	c.EnvelopeHandler = handler
+}
+
+func (c *VyperWebsocketClient) SetRawHandler(handler RawMessageHandler) {
+	c.RawHandler = handler
+}
+
+func (c *VyperWebsocketClient) SetControlHandler(handler ControlHandler) {
+	c.ControlHandler = handler
+}
+
+func (c *VyperWebsocketClient) SetErrorHandler(handler FrameErrorHandler) {
+	c.ErrorHandler = handler
+}
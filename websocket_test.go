@@ -2,9 +2,12 @@ package vyperclientgo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -177,3 +180,485 @@ func TestVyperWebsocketClient_Disconnect(t *testing.T) {
 		t.Fatal("Connection is not nil after disconnect")
 	}
 }
+
+func recordingServer(messages chan<- []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			_, message, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- message
+		}
+	}))
+}
+
+func TestVyperWebsocketClient_SubscribeTokenTypes(t *testing.T) {
+	messages := make(chan []byte, 10)
+	s := recordingServer(messages)
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	err = client.SubscribeTokenTypes(PumpfunTokens, RaydiumAmmTokens, PumpfunTokens)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Already subscribed, so nothing should be sent.
+	err = client.SubscribeTokenTypes(PumpfunTokens)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	err = client.UnsubscribeTokenTypes(RaydiumAmmTokens, RaydiumClmmTokens)
+	if err != nil {
+		t.Fatalf("Failed to unsubscribe: %v", err)
+	}
+
+	expected := []TokenSubscriptionMessage{
+		{Action: Subscribe, Types: []SubscriptionType{PumpfunTokens, RaydiumAmmTokens}},
+		{Action: Unsubscribe, Types: []SubscriptionType{RaydiumAmmTokens}},
+	}
+	for _, want := range expected {
+		select {
+		case message := <-messages:
+			var got TokenSubscriptionMessage
+			if err := json.Unmarshal(message, &got); err != nil {
+				t.Fatalf("Failed to decode message: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected message %+v, got %+v", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for subscription message")
+		}
+	}
+
+	select {
+	case message := <-messages:
+		t.Errorf("Unexpected extra message: %s", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if got := client.SubscribedTokenTypes(); !reflect.DeepEqual(got, []SubscriptionType{PumpfunTokens}) {
+		t.Errorf("Unexpected subscribed types: %v", got)
+	}
+}
+
+func TestVyperWebsocketClient_SubscribeValidation(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	if err := client.SubscribeWallets("wallet-1"); err == nil {
+		t.Error("Expected error when not connected")
+	}
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	if err := client.SubscribeWallets("wallet-1"); err == nil {
+		t.Error("Expected error subscribing wallets on token-events")
+	}
+	if err := client.SubscribeTokenTypes("NotAType"); err == nil {
+		t.Error("Expected error for unknown subscription type")
+	}
+	if got := client.SubscribedWallets(); len(got) != 0 {
+		t.Errorf("Expected no subscribed wallets, got %v", got)
+	}
+}
+
+func TestVyperWebsocketClient_SubscribeWallets(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(echo))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(WalletEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	if err := client.SubscribeWallets("wallet-b", "wallet-a"); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if err := client.SubscribeWallets(""); err == nil {
+		t.Error("Expected error for empty wallet address")
+	}
+
+	if got := client.SubscribedWallets(); !reflect.DeepEqual(got, []string{"wallet-a", "wallet-b"}) {
+		t.Errorf("Unexpected subscribed wallets: %v", got)
+	}
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Failed to disconnect: %v", err)
+	}
+	if got := client.SubscribedWallets(); len(got) != 0 {
+		t.Errorf("Expected subscriptions to be cleared on disconnect, got %v", got)
+	}
+}
+
+var benchmarkTokenPairFrame = []byte(`{"abused":false,"bondingCurvePercentage":42.17,"buyTxnCount":1834,"chainId":900,"contractCreator":"7YttLkHDoNj9wyDur5pM1ejNaAvT9X4eqaYcHQqtj2G5","createdTimestamp":1727367600000,"description":"The most memorable coin on the chain","freezeAuthority":false,"image":"https://images.vyper.trade/tokens/example.png","initialAssetLiquidity":30.0,"initialUsdLiquidity":4521.33,"isMigrated":false,"lpBurned":true,"lpCreator":"39azUYFWPz3VHgKCf3VChUwbpURdCHRxjWVowf5jUJjg","marketId":"AVs9TA4nWDzfPJE9gGVNJMVhcQy3V9PGazuz33BfG2RA","metadataUri":"https://ipfs.io/ipfs/QmExampleMetadataHash","mintAuthority":false,"name":"Example Token","pooledAsset":71.4821,"pooledToken":612345678.123,"priceChangePercent":12.5,"sellTxnCount":921,"symbol":"EXMPL","telegram":"https://t.me/example","tokenLiquidityAsset":142.96,"tokenLiquidityUsd":21544.87,"tokenMarketCapAsset":116.73,"tokenMarketCapUsd":17591.02,"tokenMint":"Ex4mpLeM1ntAddre55xxxxxxxxxxxxxxxxxxxxpump","tokenPriceAsset":0.00000011673,"tokenPriceUsd":0.0000175910,"tokenType":"Pumpfun","top10HoldingPercent":18.4,"totalSupply":1000000000,"transactionCount":2755,"twitter":"https://x.com/example","volumeAsset":512.8,"volumeUsd":77281.4,"website":"https://example.com"}`)
+
+var benchmarkChainActionFrame = []byte(`{"signer":"5tzFkiKscXHK5ZXCGbXZxdw7gTjjD1mBwuoFbhUvuAi9","tokenAccount":"GkL1tQeKwUoW1kzHx5Tb5M5H8jT9rJ1jvUQ7Zp4s3xYx","transactionId":"4uQeVj5tqViQh7yWWGStvkEG1Zmhx6uasJtWCJziofM95RJGGPQbo7AaDmGiZ9Lq8CzNVCmfyC2chgkk1qdnGXjv","tokenMint":"Ex4mpLeM1ntAddre55xxxxxxxxxxxxxxxxxxxxpump","marketId":"AVs9TA4nWDzfPJE9gGVNJMVhcQy3V9PGazuz33BfG2RA","actionType":"buy","tokenAmount":1523412.554,"assetAmount":0.25,"tokenPriceUsd":0.0000175910,"tokenPriceAsset":0.00000011673,"swapTotalUsd":37.68,"swapTotalAsset":0.25,"tokenMarketCapAsset":116.73,"tokenMarketCapUsd":17591.02,"tokenLiquidityAsset":142.96,"tokenLiquidityUsd":21544.87,"pooledToken":612345678.123,"pooledAsset":71.4821,"actionTimestamp":1727367612345,"bondingCurvePercentage":42.17,"botUsed":"Photon"}`)
+
+// decodeViaMap is the previous decoding path: unmarshal into a map, marshal
+// it back to JSON and unmarshal again into the event type. It is kept here as
+// the baseline for the benchmarks below.
+func decodeViaMap(feedType FeedType, message []byte) (interface{}, error) {
+	var rawData map[string]interface{}
+	if err := json.Unmarshal(message, &rawData); err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(rawData)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessage(feedType, jsonData)
+}
+
+func TestDecodeMessage_MatchesMapRoundTrip(t *testing.T) {
+	cases := []struct {
+		feedType FeedType
+		frame    []byte
+	}{
+		{TokenEvents, benchmarkTokenPairFrame},
+		{MigrationEvents, benchmarkTokenPairFrame},
+		{WalletEvents, benchmarkChainActionFrame},
+	}
+
+	for _, tc := range cases {
+		got, err := decodeMessage(tc.feedType, tc.frame)
+		if err != nil {
+			t.Fatalf("decodeMessage(%s) returned an error: %v", tc.feedType, err)
+		}
+		want, err := decodeViaMap(tc.feedType, tc.frame)
+		if err != nil {
+			t.Fatalf("decodeViaMap(%s) returned an error: %v", tc.feedType, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %+v, got %+v", tc.feedType, want, got)
+		}
+	}
+
+	if _, err := decodeMessage("unknown-feed", benchmarkTokenPairFrame); err == nil {
+		t.Error("Expected error for unknown feed type")
+	}
+}
+
+func benchmarkDecode(b *testing.B, decode func(FeedType, []byte) (interface{}, error), feedType FeedType, frame []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+	for i := 0; i < b.N; i++ {
+		if _, err := decode(feedType, frame); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeTokenPair(b *testing.B) {
+	benchmarkDecode(b, decodeMessage, TokenEvents, benchmarkTokenPairFrame)
+}
+
+func BenchmarkDecodeTokenPairViaMap(b *testing.B) {
+	benchmarkDecode(b, decodeViaMap, TokenEvents, benchmarkTokenPairFrame)
+}
+
+func BenchmarkDecodeChainAction(b *testing.B) {
+	benchmarkDecode(b, decodeMessage, WalletEvents, benchmarkChainActionFrame)
+}
+
+func BenchmarkDecodeChainActionViaMap(b *testing.B) {
+	benchmarkDecode(b, decodeViaMap, WalletEvents, benchmarkChainActionFrame)
+}
+
+func malformedFrameServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.TextMessage, []byte(`{"marketId":`))
+		data, _ := json.Marshal(TokenPair{MarketId: "test-market"})
+		c.WriteMessage(websocket.TextMessage, data)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+}
+
+func TestVyperWebsocketClient_ListenSkipsMalformedFrames(t *testing.T) {
+	s := malformedFrameServer()
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ErrorPolicy = ErrorPolicySkip
+
+	var badFrames []string
+	var events []interface{}
+	client.SetErrorHandler(func(message []byte, err error) {
+		badFrames = append(badFrames, string(message))
+	})
+	client.SetMessageHandler(func(data interface{}) {
+		events = append(events, data)
+	})
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	err = client.Listen()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("Expected normal closure, got %v", err)
+	}
+
+	if len(badFrames) != 1 || badFrames[0] != `{"marketId":` {
+		t.Errorf("Unexpected reported frames: %v", badFrames)
+	}
+	if len(events) != 1 {
+		t.Errorf("Expected the valid frame to be delivered, got %d events", len(events))
+	}
+	if got := client.Stats().Skipped; got != 1 {
+		t.Errorf("Expected 1 skipped frame, got %d", got)
+	}
+}
+
+func TestVyperWebsocketClient_ListenAbortsOnMalformedFrame(t *testing.T) {
+	s := malformedFrameServer()
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	reported := 0
+	client.SetErrorHandler(func(message []byte, err error) {
+		reported++
+	})
+	client.SetMessageHandler(func(data interface{}) {
+		t.Errorf("Unexpected event after malformed frame: %+v", data)
+	})
+
+	err := client.Connect(TokenEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	var syntaxErr *json.SyntaxError
+	if err := client.Listen(); !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expected a JSON syntax error, got %v", err)
+	}
+	if reported != 1 {
+		t.Errorf("Expected the frame to be reported once, got %d", reported)
+	}
+	if got := client.Stats().Skipped; got != 0 {
+		t.Errorf("Expected no skipped frames, got %d", got)
+	}
+}
+
+func TestVyperWebsocketClient_RawHandler(t *testing.T) {
+	frames := []string{
+		`{"marketId":"test-market","unknownField":{"nested":true}}`,
+		`{"marketId":`,
+	}
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for _, frame := range frames {
+			c.WriteMessage(websocket.TextMessage, []byte(frame))
+		}
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	var raw []RawMessage
+	client.SetRawHandler(func(message RawMessage) {
+		raw = append(raw, message)
+	})
+
+	err := client.Connect(MigrationEvents)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	start := time.Now()
+	// Without typed consumers frames are not decoded, so the malformed one
+	// does not end the stream.
+	err = client.Listen()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("Expected normal closure, got %v", err)
+	}
+
+	if len(raw) != len(frames) {
+		t.Fatalf("Expected %d raw frames, got %d", len(frames), len(raw))
+	}
+	for i, message := range raw {
+		if string(message.Data) != frames[i] {
+			t.Errorf("Frame %d: expected %s, got %s", i, frames[i], message.Data)
+		}
+		if message.FeedType != MigrationEvents {
+			t.Errorf("Frame %d: expected feed %s, got %s", i, MigrationEvents, message.FeedType)
+		}
+		if message.ReceivedAt.Before(start) {
+			t.Errorf("Frame %d: receive time %v is before Listen started", i, message.ReceivedAt)
+		}
+	}
+}
+
+func TestVyperWebsocketClient_EnvelopeHandler(t *testing.T) {
+	eventFrame, _ := json.Marshal(ChainAction{Signer: "wallet-1", TransactionId: "tx-1"})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.TextMessage, []byte(`{"status":"subscribed"}`))
+		c.WriteMessage(websocket.TextMessage, eventFrame)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	var envelopes []*Envelope
+	client.SetEnvelopeHandler(func(env *Envelope) {
+		envelopes = append(envelopes, env)
+	})
+
+	connectionIDs := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		err := client.Connect(WalletEvents)
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		connectionIDs[client.ConnectionID()] = true
+
+		start := time.Now()
+		client.Listen()
+		client.Disconnect()
+
+		env := envelopes[len(envelopes)-1]
+		if len(envelopes) != i+1 {
+			t.Fatalf("Expected %d envelopes, got %d", i+1, len(envelopes))
+		}
+		// The acknowledgement took sequence number 1.
+		if env.Sequence != 2 {
+			t.Errorf("Expected sequence 2, got %d", env.Sequence)
+		}
+		if env.FeedType != WalletEvents || env.Size != len(eventFrame) {
+			t.Errorf("Unexpected envelope metadata: %+v", env)
+		}
+		if !connectionIDs[env.ConnectionID] {
+			t.Errorf("Unexpected connection ID %q", env.ConnectionID)
+		}
+		if env.ReceivedAt.Before(start) {
+			t.Errorf("Receive time %v is before Listen started", env.ReceivedAt)
+		}
+		if action, ok := env.Data.(*ChainAction); !ok || action.TransactionId != "tx-1" {
+			t.Errorf("Unexpected envelope data: %+v", env.Data)
+		}
+	}
+
+	if len(connectionIDs) != 2 {
+		t.Errorf("Expected a new connection ID per connection, got %v", connectionIDs)
+	}
+}
+
+func TestVyperWebsocketClient_RedactsApiKey(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer s.Close()
+
+	apiKey := "secret/key+123"
+	client := NewVyperWebsocketClient(apiKey)
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+
+	err := client.Connect(TokenEvents)
+	if err == nil {
+		t.Fatal("Expected connect to fail")
+	}
+
+	var wsErr *VyperWebsocketError
+	if !errors.As(err, &wsErr) {
+		t.Fatalf("Expected a VyperWebsocketError, got %T", err)
+	}
+	escaped := url.QueryEscape(apiKey)
+	for _, text := range []string{err.Error(), fmt.Sprint(wsErr.ConnectionInfo), fmt.Sprint(client), fmt.Sprintf("%+v", wsErr)} {
+		if strings.Contains(text, apiKey) || strings.Contains(text, escaped) {
+			t.Errorf("API key leaked in %q", text)
+		}
+	}
+	if !strings.Contains(fmt.Sprint(wsErr.ConnectionInfo), "apiKey=REDACTED") {
+		t.Errorf("Expected redacted URL, got %v", wsErr.ConnectionInfo)
+	}
+}
+
+func TestVyperWebsocketClient_ApiKeyInHeader(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		echo(w, r)
+	}))
+	defer s.Close()
+
+	client := NewVyperWebsocketClient("test-api-key")
+	client.BaseURL = "ws" + strings.TrimPrefix(s.URL, "http")
+	client.ApiKeyInHeader = true
+
+	if err := client.Connect(WalletEvents); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	r := <-requests
+	if got := r.Header.Get("X-API-Key"); got != "test-api-key" {
+		t.Errorf("Expected X-API-Key header, got %q", got)
+	}
+	if r.URL.Query().Has("apiKey") {
+		t.Errorf("Expected no apiKey query parameter, got %s", r.URL.RawQuery)
+	}
+}
+
+func TestRedactSecret(t *testing.T) {
+	got := redactSecret(`dial "wss://host/ws?apiKey=a%2Fb+c": a/b c`, "a/b c")
+	if strings.Contains(got, "a/b c") || strings.Contains(got, "a%2Fb+c") {
+		t.Errorf("Secret not redacted: %s", got)
+	}
+	if got := redactSecret("no secret here", ""); got != "no secret here" {
+		t.Errorf("Unexpected change with empty secret: %s", got)
+	}
+}